@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"time"
+
+	"radio-to-spotify/scraper"
+)
+
+const (
+	// Time after the expected end of a song during which the station is polled densely
+	adaptiveGrace = 1 * time.Minute
+	// Weight of a new observation in the learned average song length
+	adaptiveLearningRate = 0.2
+	// Observed song lengths outside this range (talk, news, missed changes) are not learned
+	minLearnedSongLength = 30 * time.Second
+	maxLearnedSongLength = 15 * time.Minute
+)
+
+// adaptiveSchedule predicts when the song of a station changes and schedules the next fetch shortly before that,
+// polling densely around the expected change. The song length is taken from the feed or learned from past changes.
+type adaptiveSchedule struct {
+	minInterval time.Duration
+	maxInterval time.Duration
+	current     string        // Artist and title of the current song
+	songStart   time.Time     // When the current song started, as reported by the feed or first seen
+	avgLength   time.Duration // Learned average song length of the station
+}
+
+func newAdaptiveSchedule(minInterval, maxInterval time.Duration) *adaptiveSchedule {
+	return &adaptiveSchedule{
+		minInterval: minInterval,
+		maxInterval: maxInterval,
+	}
+}
+
+// next returns the delay until the next fetch after the given song was fetched. Without a song or a prediction the
+// fallback is used.
+func (a *adaptiveSchedule) next(song *scraper.Song, now time.Time, fallback time.Duration) time.Duration {
+	if song == nil {
+		return fallback
+	}
+
+	key := song.Artist + "\x00" + song.Title
+	if key != a.current {
+		start := now
+		if song.PlayedAt != nil && song.PlayedAt.Before(now) {
+			start = *song.PlayedAt
+		}
+		if a.current != "" {
+			a.learn(start.Sub(a.songStart))
+		}
+		a.current = key
+		a.songStart = start
+	}
+
+	length := song.Duration
+	if length <= 0 {
+		length = a.avgLength
+	}
+	if length <= 0 {
+		return fallback
+	}
+
+	untilEnd := a.songStart.Add(length).Sub(now)
+	var delay time.Duration
+	switch {
+	case untilEnd > a.minInterval:
+		// Wake up shortly before the song is expected to end
+		delay = untilEnd - a.minInterval
+	case untilEnd > -adaptiveGrace:
+		delay = a.minInterval
+	default:
+		// The song runs much longer than predicted, fall back to regular polling
+		delay = fallback
+	}
+
+	return max(a.minInterval, min(delay, a.maxInterval))
+}
+
+// learn updates the average song length with an observed length
+func (a *adaptiveSchedule) learn(length time.Duration) {
+	if length < minLearnedSongLength || length > maxLearnedSongLength {
+		return
+	}
+	if a.avgLength == 0 {
+		a.avgLength = length
+		return
+	}
+	a.avgLength += time.Duration(adaptiveLearningRate * float64(length-a.avgLength))
+}
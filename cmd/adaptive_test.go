@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"radio-to-spotify/scraper"
+)
+
+const (
+	testMinInterval = 15 * time.Second
+	testMaxInterval = 10 * time.Minute
+	testFallback    = time.Minute
+)
+
+var testNow = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func playedAgo(d time.Duration) *time.Time {
+	t := testNow.Add(-d)
+	return &t
+}
+
+func TestAdaptiveScheduleNext(t *testing.T) {
+	tests := []struct {
+		name string
+		song *scraper.Song
+		want time.Duration
+	}{
+		{name: "no song", want: testFallback},
+		{name: "no duration", song: &scraper.Song{Artist: "A", Title: "T"}, want: testFallback},
+		{name: "new song", song: &scraper.Song{Artist: "A", Title: "T", Duration: 4 * time.Minute}, want: 4*time.Minute - testMinInterval},
+		{name: "play time", song: &scraper.Song{Artist: "A", Title: "T", Duration: 4 * time.Minute, PlayedAt: playedAgo(3 * time.Minute)}, want: 45 * time.Second},
+		{name: "play time in the future", song: &scraper.Song{Artist: "A", Title: "T", Duration: 4 * time.Minute, PlayedAt: playedAgo(-time.Minute)}, want: 4*time.Minute - testMinInterval},
+		{name: "about to end", song: &scraper.Song{Artist: "A", Title: "T", Duration: 4 * time.Minute, PlayedAt: playedAgo(4*time.Minute - 10*time.Second)}, want: testMinInterval},
+		{name: "within grace", song: &scraper.Song{Artist: "A", Title: "T", Duration: 4 * time.Minute, PlayedAt: playedAgo(4*time.Minute + 30*time.Second)}, want: testMinInterval},
+		{name: "past grace", song: &scraper.Song{Artist: "A", Title: "T", Duration: 4 * time.Minute, PlayedAt: playedAgo(10 * time.Minute)}, want: testFallback},
+		{name: "long song", song: &scraper.Song{Artist: "A", Title: "T", Duration: time.Hour}, want: testMaxInterval},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := newAdaptiveSchedule(testMinInterval, testMaxInterval)
+			if got := schedule.next(tt.song, testNow, testFallback); got != tt.want {
+				t.Errorf("next = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveScheduleLearning(t *testing.T) {
+	schedule := newAdaptiveSchedule(testMinInterval, testMaxInterval)
+	now := testNow
+
+	steps := []struct {
+		after     time.Duration
+		song      string
+		want      time.Duration
+		wantAvg   time.Duration
+		wantSince time.Duration // Time since the current song started
+	}{
+		// Nothing learned from the first song
+		{song: "first", want: testFallback},
+		{after: time.Minute, song: "first", want: testFallback, wantSince: time.Minute},
+		// The first observed change
+		{after: 2 * time.Minute, song: "second", want: 3*time.Minute - testMinInterval, wantAvg: 3 * time.Minute},
+		{after: 2 * time.Minute, song: "second", want: time.Minute - testMinInterval, wantAvg: 3 * time.Minute, wantSince: 2 * time.Minute},
+		// Moves a fifth of the way towards a new length
+		{after: 2 * time.Minute, song: "third", want: 3*time.Minute + 12*time.Second - testMinInterval, wantAvg: 3*time.Minute + 12*time.Second},
+		// Too short to be a song, e.g. a missed change
+		{after: 10 * time.Second, song: "fourth", want: 3*time.Minute + 12*time.Second - testMinInterval, wantAvg: 3*time.Minute + 12*time.Second},
+	}
+
+	for i, step := range steps {
+		now = now.Add(step.after)
+		got := schedule.next(&scraper.Song{Artist: "A", Title: step.song}, now, testFallback)
+		if got != step.want || schedule.avgLength != step.wantAvg || now.Sub(schedule.songStart) != step.wantSince {
+			t.Fatalf("step %d: next = %v with average %v, song started %v ago, want %v, %v and %v", i+1,
+				got, schedule.avgLength, now.Sub(schedule.songStart), step.want, step.wantAvg, step.wantSince)
+		}
+	}
+}
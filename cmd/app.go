@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"radio-to-spotify/scraper"
+	"radio-to-spotify/spotify"
+	"radio-to-spotify/storage"
+	"radio-to-spotify/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// App is the application context shared by the commands. It is created once from the config before a command runs.
+// The stations, storage, cache and Spotify service are set up on first use, so each command only opens what it needs
+// and nothing is opened twice.
+type App struct {
+	Config *utils.AppConfig
+	Logger *logrus.Logger
+
+	stations *utils.ConfigHandler
+	store    storage.Storage
+	cache    *storage.SongCache
+	spotify  *spotify.SpotifyService
+}
+
+func newApp(config *utils.AppConfig) *App {
+	utils.SetLevel(config.LogLevel)
+	scraper.SetNormalizeRules(config.Normalize)
+	return &App{
+		Config: config,
+		Logger: utils.Logger,
+	}
+}
+
+// Stations returns the handler of the stations file
+func (a *App) Stations() (*utils.ConfigHandler, error) {
+	if a.stations == nil {
+		stations, err := utils.NewConfigHandler(a.Config.StationFile)
+		if err != nil {
+			return nil, err
+		}
+		a.stations = stations
+	}
+	return a.stations, nil
+}
+
+// Storage returns the configured storage, initialized on first use
+func (a *App) Storage() (storage.Storage, error) {
+	if a.store == nil {
+		store, err := storage.NewStorage(a.Config.Storage, a.Config.StoragePath)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.Init(context.Background()); err != nil {
+			store.Close()
+			return nil, err
+		}
+		a.store = store
+	}
+	return a.store, nil
+}
+
+// Close closes the storage if it was opened
+func (a *App) Close() error {
+	if a.store == nil {
+		return nil
+	}
+	err := a.store.Close()
+	a.store = nil
+	return err
+}
+
+// Cache returns the cache of Spotify track IDs
+func (a *App) Cache() *storage.SongCache {
+	if a.cache == nil {
+		a.cache = storage.NewSongCache(a.Config.Cache)
+	}
+	return a.cache
+}
+
+// Spotify returns the Spotify service, logging in on first use
+func (a *App) Spotify() (*spotify.SpotifyService, error) {
+	if a.spotify != nil {
+		return a.spotify, nil
+	}
+
+	stations, err := a.Stations()
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %v", err)
+	}
+	store, err := a.Storage()
+	if err != nil {
+		return nil, fmt.Errorf("error initializing storage: %v", err)
+	}
+
+	spotifyService, err := spotify.NewSpotifyService(a.Config.Spotify, stations, store, a.Cache())
+	if err != nil {
+		return nil, err
+	}
+	a.spotify = spotifyService
+	return a.spotify, nil
+}
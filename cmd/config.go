@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"radio-to-spotify/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective settings and where each one comes from",
+	Long: `Show the effective settings and where each one comes from. Settings are read from, in order of precedence,
+command line flags, environment variables, the config file and the defaults. Secrets are redacted.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		executeConfigShow()
+	},
+}
+
+func executeConfigShow() {
+	if file := viper.ConfigFileUsed(); file != "" {
+		fmt.Printf("Config file: %s\n\n", file)
+	} else {
+		fmt.Printf("No config file found\n\n")
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "KEY\tVALUE\tSOURCE")
+	for _, setting := range utils.Settings {
+		value := fmt.Sprint(viper.Get(setting.Key))
+		if _, ok := setting.Default.(time.Duration); ok {
+			value = viper.GetDuration(setting.Key).String()
+		}
+		if setting.Secret && value != "" {
+			value = "<redacted>"
+		}
+
+		source := utils.SettingSource(viper.GetViper(), setting)
+		if flag, ok := boundFlags[setting.Key]; ok && flag.Changed {
+			source = "flag --" + flag.Name
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", setting.Key, value, source)
+	}
+	writer.Flush()
+}
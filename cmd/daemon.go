@@ -1,10 +1,10 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"os"
 	"os/signal"
-	"strconv"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -17,31 +17,37 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var (
-	noStore                  bool
-	noPlaylist               bool
-	fetchInterval            time.Duration
-	playlistUpdateInterval   time.Duration
-	playlistRange            string
-	sessionKeepAliveInterval time.Duration
-)
+var sessionKeepAliveInterval time.Duration
 
 type ScraperService struct {
-	FetchInterval            time.Duration
+	FetchInterval            time.Duration // Default interval for stations without their own fetchInterval
 	PlaylistUpdateInterval   time.Duration
 	SessionKeepAliveInterval time.Duration
+	Adaptive                 bool          // Predict song changes instead of polling on a fixed interval
+	AdaptiveMinInterval      time.Duration // Polling interval around an expected song change
+	AdaptiveMaxInterval      time.Duration // Longest time between two fetches of a station
+	PlaylistRange            string
+	StationID                string // Only work on this station if set
+	StationFile              string
+	NoStore                  bool            // Only update the playlists
+	NoPlaylist               bool            // Only store the songs
+	ctx                      context.Context // Cancelled on shutdown to abort running storage and Spotify calls
+	cancel                   context.CancelFunc
 	stopScraper              chan struct{}
+	stopped                  chan struct{}
 	configHandler            *utils.ConfigHandler
 	storage                  storage.Storage
 	spotify                  *spotify.SpotifyService
+	workersMu                sync.Mutex
+	workers                  map[string]*stationWorker
 }
 
 func (s *ScraperService) Start() {
-	utils.Logger.Infof("Starting scraper service with fetch interval %v", s.FetchInterval)
-	fetchTicker := time.NewTicker(s.FetchInterval)
+	defer close(s.stopped)
+	utils.Logger.Infof("Starting scraper service with default fetch interval %v", s.FetchInterval)
 	var playlistUpdateTicker *time.Ticker
 
-	if !noPlaylist {
+	if !s.NoPlaylist {
 		utils.Logger.Infof("Starting playlist update ticker with interval %v", s.PlaylistUpdateInterval)
 		playlistUpdateTicker = time.NewTicker(s.PlaylistUpdateInterval)
 	} else { // If no playlist update, don't start the tickers / Kinda hacky
@@ -50,13 +56,15 @@ func (s *ScraperService) Start() {
 		playlistUpdateTicker.Stop()
 	}
 
+	if !s.NoStore {
+		s.startWorkers()
+	} else {
+		utils.Logger.Info("Running without storing now playing songs")
+	}
+
 	wg := sync.WaitGroup{}
 	for {
 		select {
-		case <-fetchTicker.C:
-			utils.Logger.Debug("FetchTicker tick")
-			wg.Add(1)
-			go s.fetchNowPlaying(&wg)
 		case <-playlistUpdateTicker.C:
 			utils.Logger.Debug("PlaylistUpdateTicker tick")
 			wg.Add(1)
@@ -64,8 +72,8 @@ func (s *ScraperService) Start() {
 		case <-s.stopScraper:
 			utils.Logger.Debug("Stop signal received")
 			utils.Logger.Info("Waiting for goroutines to finish")
+			s.stopWorkers()
 			wg.Wait()
-			fetchTicker.Stop()
 			if playlistUpdateTicker != nil {
 				playlistUpdateTicker.Stop()
 			}
@@ -76,45 +84,57 @@ func (s *ScraperService) Start() {
 
 func (s *ScraperService) Stop() {
 	utils.Logger.Info("Stopping scraper service")
+	s.cancel()
 	close(s.stopScraper)
+	<-s.stopped
 }
 
-func (s *ScraperService) fetchNowPlaying(wg *sync.WaitGroup) {
-	defer wg.Done()
+// reloadStations reloads the stations file and reschedules the stations without restarting. If the new file is
+// invalid, the current stations are kept.
+func (s *ScraperService) reloadStations() {
+	utils.Logger.Infof("Reloading stations from %s", s.StationFile)
+	if err := s.configHandler.Reload(); err != nil {
+		utils.Logger.Errorf("Error reloading stations, keeping the current stations: %v", err)
+		return
+	}
+
+	if s.NoStore {
+		return
+	}
+	s.reconcileWorkers()
+}
+
+// fetchStation fetches a single station and stores the songs played since the last fetch. Returns the current song,
+// or nil if the station couldn't be fetched.
+func (s *ScraperService) fetchStation(ctx context.Context, station *utils.Station) *scraper.Song {
 	utils.SetLastUpdateTime("fetch", time.Now())
-	utils.Logger.Debugf("Fetching now playing songs")
-	var storedCount, songCount int
 
-	if !noStore {
-		stations, songs, err := scraper.FetchNowPlaying(s.configHandler, stationID)
-		if err != nil {
-			utils.Logger.Warnf("Error fetching now playing: %v", err)
-			return
-		}
-		songCount = len(songs)
-
-		for i, station := range stations {
-			changed, err := s.storage.StoreNowPlaying(station.ID, songs[i])
-			if err != nil {
-				utils.Logger.Errorf("Error storing now playing for station %s: %v", station.ID, err)
-			} else {
-				if changed {
-					// Only count stored songs that have changed
-					storedCount++
-					utils.Logger.Debugf("Stored song for station %s: %s - %s", station.ID, songs[i].Artist, songs[i].Title)
-				} else {
-					utils.Logger.Debugf("No change in song for station %s: %s - %s", station.ID, songs[i].Artist, songs[i].Title)
-				}
-			}
-		}
+	history, err := scraper.FetchStation(ctx, station)
+	if errors.Is(err, scraper.ErrBreakerOpen) {
+		utils.Logger.Debugf("Skipping station %s: %v", station.ID, err)
+		return nil
+	}
+	if err != nil {
+		utils.Logger.Warnf("Error fetching now playing for station %s (%s): %v", station.Name, station.ID, err)
+		return nil
 	}
 
-	utils.Logger.Infof("Fetched %d stations, stored %d songs", songCount, storedCount)
+	song := history[0]
+	// Backfill songs from the station history that were played since the last fetch
+	stored, err := storage.StoreHistory(ctx, s.storage, station.ID, history)
+	if err != nil {
+		utils.Logger.Errorf("Error storing now playing for station %s: %v", station.ID, err)
+	} else if stored > 0 {
+		utils.Logger.Infof("Stored %d song(s) for station %s, now playing: %s - %s", stored, station.ID, song.Artist, song.Title)
+	} else {
+		utils.Logger.Debugf("No change in song for station %s: %s - %s", station.ID, song.Artist, song.Title)
+	}
+	return song
 }
 
 func (s *ScraperService) updatePlaylists(wg *sync.WaitGroup) {
 	defer wg.Done()
-	if noPlaylist {
+	if s.NoPlaylist {
 		return
 	}
 
@@ -122,19 +142,19 @@ func (s *ScraperService) updatePlaylists(wg *sync.WaitGroup) {
 	utils.Logger.Debugf("Updating playlists")
 	var playlistCount int
 
-	stations, err := s.storage.GetAllStations()
+	stations, err := s.storage.GetAllStations(s.ctx)
 	if err != nil {
 		utils.Logger.Errorf("Error getting all stations: %v", err)
 		return
 	}
-	if stationID != "" {
-		stations = []string{stationID}
+	if s.StationID != "" {
+		stations = []string{s.StationID}
 	} else {
 		utils.Logger.Debugf("Updating playlists for all stations")
 	}
 
 	for _, stationID := range stations {
-		err := s.spotify.UpdateSpotifyPlaylist(stationID, playlistRange)
+		err := s.spotify.UpdateSpotifyPlaylist(s.ctx, stationID, s.PlaylistRange)
 		if err != nil {
 			utils.Logger.Errorf("Error updating Spotify playlist for station %s: %v", stationID, err)
 		} else {
@@ -148,41 +168,59 @@ func (s *ScraperService) updatePlaylists(wg *sync.WaitGroup) {
 var daemonCmd = &cobra.Command{
 	Use:   "daemon",
 	Short: "Start the daemon to scrape now playing songs periodically",
-	Run:   runDaemon,
+	Run: func(cmd *cobra.Command, args []string) {
+		runDaemon(app)
+	},
 }
 
 func init() {
-	daemonCmd.Flags().BoolVar(&noStore, "no-store", false, "Run without storing the now playing songs")
-	daemonCmd.Flags().BoolVar(&noPlaylist, "no-playlist", false, "Run without updating the Spotify playlist")
-	daemonCmd.Flags().DurationVar(&fetchInterval, "fetch-interval", 1*time.Minute, "Interval between scrapes (e.g., 30s, 1m, 5m)")
-	daemonCmd.Flags().DurationVar(&playlistUpdateInterval, "playlist-update-interval", 1*time.Hour, "Interval between playlist updates (e.g., 30m, 1h, 5h)")
-	daemonCmd.Flags().StringVar(&playlistRange, "playlist-range", "lastday", "Time range for playlist update (lasthour, lastday, lastweek)")
+	daemonCmd.Flags().Bool("no-store", false, "Run without storing the now playing songs")
+	daemonCmd.Flags().Bool("no-playlist", false, "Run without updating the Spotify playlist")
+	daemonCmd.Flags().Duration("fetch-interval", 1*time.Minute, "Default interval between scrapes of a station without its own fetchInterval (e.g., 30s, 1m, 5m)")
+	daemonCmd.Flags().Bool("adaptive", false, "Predict song changes from track durations and poll around them instead of on a fixed interval")
+	daemonCmd.Flags().Duration("adaptive-min-interval", 15*time.Second, "Polling interval around an expected song change in adaptive mode")
+	daemonCmd.Flags().Duration("adaptive-max-interval", 10*time.Minute, "Longest time between two scrapes of a station in adaptive mode")
+	daemonCmd.Flags().Duration("playlist-update-interval", 1*time.Hour, "Interval between playlist updates (e.g., 30m, 1h, 5h)")
+	daemonCmd.Flags().String("playlist-range", "lastday", "Time range for playlist update (lasthour, lastday, lastweek)")
+	bindFlags(daemonCmd, map[string]string{
+		"daemon.no_store":                 "no-store",
+		"daemon.no_playlist":              "no-playlist",
+		"daemon.fetch_interval":           "fetch-interval",
+		"daemon.adaptive":                 "adaptive",
+		"daemon.adaptive_min_interval":    "adaptive-min-interval",
+		"daemon.adaptive_max_interval":    "adaptive-max-interval",
+		"daemon.playlist_update_interval": "playlist-update-interval",
+		"playlist_range":                  "playlist-range",
+	})
 	rootCmd.AddCommand(daemonCmd)
 }
 
-func runDaemon(cmd *cobra.Command, args []string) {
+func runDaemon(app *App) {
 	utils.Logger.Info("Starting daemon")
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
-	configHandler, err := utils.NewConfigHandler(stationFile)
-	if err != nil {
-		utils.Logger.Fatalf("Error loading config: %v", err)
+	daemonConfig := app.Config.Daemon
+	if daemonConfig.FetchInterval <= 0 {
+		utils.Logger.Fatalf("Invalid fetch interval: %v", daemonConfig.FetchInterval)
+	}
+	if daemonConfig.Adaptive && (daemonConfig.AdaptiveMinInterval <= 0 || daemonConfig.AdaptiveMaxInterval < daemonConfig.AdaptiveMinInterval) {
+		utils.Logger.Fatalf("Invalid adaptive intervals: min %v, max %v", daemonConfig.AdaptiveMinInterval, daemonConfig.AdaptiveMaxInterval)
 	}
 
-	store, err := storage.NewStorage(storageType, storagePath)
+	configHandler, err := app.Stations()
 	if err != nil {
-		utils.Logger.Fatalf("Error initializing storage: %v", err)
+		utils.Logger.Fatalf("Error loading config: %v", err)
 	}
 
-	err = store.Init()
+	store, err := app.Storage()
 	if err != nil {
 		utils.Logger.Fatalf("Error initializing storage: %v", err)
 	}
 
 	var spotifyService *spotify.SpotifyService
-	if !noPlaylist {
-		spotifyService, err = spotify.NewSpotifyService(configHandler, store)
+	if !daemonConfig.NoPlaylist {
+		spotifyService, err = app.Spotify()
 		if err != nil {
 			utils.Logger.Fatalf("Error initializing Spotify service: %v", err)
 		}
@@ -190,33 +228,72 @@ func runDaemon(cmd *cobra.Command, args []string) {
 		utils.Logger.Info("Running without Spotify playlist update")
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	scraperService := &ScraperService{
-		FetchInterval:            fetchInterval,            // Use the fetch interval from the flag
-		PlaylistUpdateInterval:   playlistUpdateInterval,   // Use the playlist update interval from the flag
+		FetchInterval:            daemonConfig.FetchInterval,
+		PlaylistUpdateInterval:   daemonConfig.PlaylistUpdateInterval,
 		SessionKeepAliveInterval: sessionKeepAliveInterval, // Use the session keep alive interval from the flag
+		Adaptive:                 daemonConfig.Adaptive,
+		AdaptiveMinInterval:      daemonConfig.AdaptiveMinInterval,
+		AdaptiveMaxInterval:      daemonConfig.AdaptiveMaxInterval,
+		PlaylistRange:            app.Config.PlaylistRange,
+		StationID:                app.Config.StationID,
+		StationFile:              app.Config.StationFile,
+		NoStore:                  daemonConfig.NoStore,
+		NoPlaylist:               daemonConfig.NoPlaylist,
+		ctx:                      ctx,
+		cancel:                   cancel,
 		stopScraper:              make(chan struct{}),
+		stopped:                  make(chan struct{}),
+		workers:                  make(map[string]*stationWorker),
 		configHandler:            configHandler,
 		storage:                  store,
 		spotify:                  spotifyService,
 	}
 
-	if healthCheckEnv := utils.GetEnv("ENABLE_HEALTHCHECK", "false"); strings.ToLower(healthCheckEnv) == "true" {
-		portEnv := utils.GetEnv("HEALTHCHECK_PORT", "8585")
-		port, err := strconv.Atoi(portEnv)
-		if err != nil {
-			utils.Logger.Fatalf("Error parsing healthcheck port: %v", err)
-		}
-		if noPlaylist {
+	if app.Config.HealthCheck.Enabled {
+		playlistUpdateInterval := daemonConfig.PlaylistUpdateInterval
+		if daemonConfig.NoPlaylist {
 			playlistUpdateInterval = 0
 		}
-		go utils.StartHealthCheckServer(port, fetchInterval, playlistUpdateInterval, spotifyService)
+		go utils.StartHealthCheckServer(app.Config.HealthCheck.Port, scraperService.healthFetchInterval, playlistUpdateInterval, spotifyService)
 	}
 	go scraperService.Start()
 
-	<-stop
+	// Reload the stations on SIGHUP and whenever the stations file changes
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	fileChanged := make(chan struct{}, 1)
+	stopWatching := make(chan struct{})
+	defer close(stopWatching)
+	err = configHandler.Watch(func() {
+		select {
+		case fileChanged <- struct{}{}:
+		default: // A reload is already pending
+		}
+	}, stopWatching)
+	if err != nil {
+		utils.Logger.Warnf("Error watching %s, reload the stations with SIGHUP instead: %v", app.Config.StationFile, err)
+	}
+
+	for running := true; running; {
+		select {
+		case <-reload:
+			utils.Logger.Info("Received SIGHUP")
+			scraperService.reloadStations()
+		case <-fileChanged:
+			utils.Logger.Debugf("%s changed", app.Config.StationFile)
+			scraperService.reloadStations()
+		case <-stop:
+			running = false
+		}
+	}
 
 	utils.Logger.Info("Received interrupt signal")
 
 	scraperService.Stop()
+	if err := app.Close(); err != nil {
+		utils.Logger.Errorf("Error closing storage: %v", err)
+	}
 	utils.Logger.Info("Stopped daemon")
 }
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+
 	"radio-to-spotify/scraper"
 	"radio-to-spotify/utils"
 
@@ -15,17 +17,17 @@ var fetchCmd = &cobra.Command{
 	Use:   "fetch",
 	Short: "Fetch now playing songs for radio stations",
 	Run: func(cmd *cobra.Command, args []string) {
-		executeFetch()
+		executeFetch(app)
 	},
 }
 
-func executeFetch() {
-	configHandler, err := utils.NewConfigHandler(stationFile)
+func executeFetch(app *App) {
+	configHandler, err := app.Stations()
 	if err != nil {
 		utils.Logger.Fatalf("Error loading config: %v", err)
 	}
 
-	stations, songs, err := scraper.FetchNowPlaying(configHandler, stationID)
+	stations, songs, err := scraper.FetchNowPlaying(context.Background(), configHandler, app.Config.StationID)
 	if err != nil {
 		utils.Logger.Fatalf("Error fetching now playing: %v", err)
 	}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"radio-to-spotify/scraper"
+	"radio-to-spotify/spotify"
+	"radio-to-spotify/storage"
+	"radio-to-spotify/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	reviewSince      time.Duration
+	reviewBelow      float64
+	reviewLimit      int
+	reviewCandidates int
+)
+
+func init() {
+	matchReviewCmd.Flags().DurationVar(&reviewSince, "since", 24*time.Hour, "Review songs played within this time (e.g., 1h, 24h, 168h)")
+	matchReviewCmd.Flags().Float64Var(&reviewBelow, "below", 0.8, "Review matches with a confidence below this score")
+	matchReviewCmd.Flags().IntVar(&reviewLimit, "limit", 20, "Maximum number of songs to review")
+	matchReviewCmd.Flags().IntVar(&reviewCandidates, "candidates", 3, "Number of Spotify candidates to show for each song")
+
+	matchCmd.AddCommand(matchReviewCmd)
+	matchCmd.AddCommand(matchSetCmd)
+	matchCmd.AddCommand(matchSkipCmd)
+	matchCmd.AddCommand(matchClearCmd)
+	rootCmd.AddCommand(matchCmd)
+}
+
+var matchCmd = &cobra.Command{
+	Use:   "match",
+	Short: "Review and fix the Spotify tracks songs are matched to",
+	Long: `Review and fix the Spotify tracks songs are matched to. Overrides set with match set or match skip are used
+instead of the cache and the stored matches, and are kept until they are cleared.`,
+}
+
+var matchReviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "List recently played songs without a match or with a low confidence match, with Spotify candidates",
+	Long: `List recently played songs without a match or with a low confidence match, with Spotify candidates.
+Only songs of --station are reviewed if it is set. Songs with an override are left out.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		executeMatchReview(app)
+	},
+}
+
+var matchSetCmd = &cobra.Command{
+	Use:   "set <artist> <title> <track>",
+	Short: "Always match a song to a Spotify track, given as ID, URI or URL",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		executeMatchSet(app, args[0], args[1], args[2])
+	},
+}
+
+var matchSkipCmd = &cobra.Command{
+	Use:   "skip <artist> <title>",
+	Short: "Leave a song out of playlists",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		executeMatchSkip(app, args[0], args[1])
+	},
+}
+
+var matchClearCmd = &cobra.Command{
+	Use:   "clear <artist> <title>",
+	Short: "Remove the override of a song, so it is matched automatically again",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		executeMatchClear(app, args[0], args[1])
+	},
+}
+
+// reviewSong is a recently played song that needs a review
+type reviewSong struct {
+	song     scraper.Song
+	match    *storage.TrackMatch
+	plays    int
+	stations map[string]bool
+}
+
+func executeMatchReview(app *App) {
+	ctx := context.Background()
+	store, err := app.Storage()
+	if err != nil {
+		utils.Logger.Fatalf("Error initializing storage: %v", err)
+	}
+
+	filter := storage.PlayFilter{From: time.Now().Add(-reviewSince), Descending: true}
+	if app.Config.StationID != "" {
+		filter.StationIDs = []string{app.Config.StationID}
+	}
+	plays, err := store.GetPlays(ctx, filter)
+	if err != nil {
+		utils.Logger.Fatalf("Error getting plays: %v", err)
+	}
+
+	// Songs are reviewed once, most recently played first
+	var songs []*reviewSong
+	byKey := make(map[string]*reviewSong)
+	checked := make(map[string]bool)
+	for _, play := range plays {
+		key := storage.MatchKey(play.Artist, play.Title)
+		if song, exists := byKey[key]; exists {
+			song.plays++
+			song.stations[play.StationID] = true
+			continue
+		}
+		if checked[key] || len(songs) >= reviewLimit {
+			continue
+		}
+		checked[key] = true
+
+		if override, err := store.GetTrackOverride(ctx, play.Artist, play.Title); err != nil {
+			utils.Logger.Fatalf("Error getting override: %v", err)
+		} else if override != nil {
+			continue
+		}
+		// Songs that were not searched yet are matched on the next playlist update, unscored matches predate scoring
+		match, err := store.GetTrackMatch(ctx, play.Artist, play.Title)
+		if err != nil {
+			utils.Logger.Fatalf("Error getting match: %v", err)
+		}
+		if match == nil || (!match.NoMatch && (match.Confidence == 0 || match.Confidence >= reviewBelow)) {
+			continue
+		}
+
+		song := &reviewSong{song: play.Song, match: match, plays: 1, stations: map[string]bool{play.StationID: true}}
+		byKey[key] = song
+		songs = append(songs, song)
+	}
+
+	if len(songs) == 0 {
+		fmt.Printf("No songs to review in the last %v\n", reviewSince)
+		return
+	}
+
+	spotifyService, err := app.Spotify()
+	if err != nil {
+		utils.Logger.Fatalf("Error initializing Spotify service: %v", err)
+	}
+
+	for _, song := range songs {
+		var stations []string
+		for stationID := range song.stations {
+			stations = append(stations, stationID)
+		}
+		sort.Strings(stations)
+		fmt.Printf("%s - %s (played %d times on %s)\n", song.song.Artist, song.song.Title, song.plays, strings.Join(stations, ", "))
+		if song.match.NoMatch {
+			fmt.Printf("  No match, best score %.2f\n", song.match.Confidence)
+		} else {
+			fmt.Printf("  Matched to %s with %.2f\n", song.match.SpotifyID, song.match.Confidence)
+		}
+
+		candidates, err := spotifyService.SearchCandidates(ctx, song.song)
+		if err != nil {
+			fmt.Printf("  Error searching Spotify: %v\n", err)
+			continue
+		}
+		if len(candidates) == 0 {
+			fmt.Println("  No candidates found")
+		}
+		for i, candidate := range candidates {
+			if i >= reviewCandidates {
+				break
+			}
+			var artists []string
+			for _, artist := range candidate.Track.Artists {
+				artists = append(artists, artist.Name)
+			}
+			fmt.Printf("  %d. %.2f %s - %s (%s) %s\n", i+1, candidate.Score, strings.Join(artists, ", "), candidate.Track.Name,
+				candidate.Track.Album.Name, candidate.Track.ID)
+		}
+		fmt.Println()
+	}
+	fmt.Println(`Fix a song with: match set "<artist>" "<title>" <track> or match skip "<artist>" "<title>"`)
+}
+
+func executeMatchSet(app *App, artist, title, track string) {
+	trackID, err := spotify.ParseTrackID(track)
+	if err != nil {
+		utils.Logger.Fatalf("Error: %v", err)
+	}
+
+	spotifyService, err := app.Spotify()
+	if err != nil {
+		utils.Logger.Fatalf("Error initializing Spotify service: %v", err)
+	}
+	fullTrack, err := spotifyService.GetTrack(context.Background(), trackID)
+	if err != nil {
+		utils.Logger.Fatalf("Error getting Spotify track %s: %v", trackID, err)
+	}
+
+	storeOverride(app, &storage.TrackOverride{Artist: artist, Title: title, SpotifyID: trackID.String()})
+	var artists []string
+	for _, trackArtist := range fullTrack.Artists {
+		artists = append(artists, trackArtist.Name)
+	}
+	fmt.Printf("Matched %s - %s to %s - %s (%s)\n", artist, title, strings.Join(artists, ", "), fullTrack.Name, trackID)
+}
+
+func executeMatchSkip(app *App, artist, title string) {
+	storeOverride(app, &storage.TrackOverride{Artist: artist, Title: title, Skip: true})
+	fmt.Printf("Skipping %s - %s in playlists\n", artist, title)
+}
+
+func executeMatchClear(app *App, artist, title string) {
+	store, err := app.Storage()
+	if err != nil {
+		utils.Logger.Fatalf("Error initializing storage: %v", err)
+	}
+	deleted, err := store.DeleteTrackOverride(context.Background(), artist, title)
+	if err != nil {
+		utils.Logger.Fatalf("Error removing override: %v", err)
+	}
+	if !deleted {
+		fmt.Printf("No override found for %s - %s\n", artist, title)
+		return
+	}
+	fmt.Printf("Removed the override of %s - %s\n", artist, title)
+}
+
+func storeOverride(app *App, override *storage.TrackOverride) {
+	store, err := app.Storage()
+	if err != nil {
+		utils.Logger.Fatalf("Error initializing storage: %v", err)
+	}
+	if err := store.StoreTrackOverride(context.Background(), override); err != nil {
+		utils.Logger.Fatalf("Error storing override: %v", err)
+	}
+}
@@ -1,8 +1,8 @@
 package cmd
 
 import (
+	"context"
 	"radio-to-spotify/spotify"
-	"radio-to-spotify/storage"
 	"radio-to-spotify/utils"
 	"sync"
 
@@ -13,38 +13,29 @@ var playlistCmd = &cobra.Command{
 	Use:   "playlist",
 	Short: "Create Spotify playlist for the last hour of songs",
 	Run: func(cmd *cobra.Command, args []string) {
-		executePlaylist()
+		executePlaylist(app)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(playlistCmd)
-	playlistCmd.Flags().StringVar(&playlistRange, "playlist-range", "lastday", "Time range for playlist update (lasthour, lastday, lastweek)")
+	playlistCmd.Flags().String("playlist-range", "lastday", "Time range for playlist update (lasthour, lastday, lastweek)")
+	bindFlags(playlistCmd, map[string]string{"playlist_range": "playlist-range"})
 }
 
-func executePlaylist() {
-	configHandler, err := utils.NewConfigHandler(stationFile)
+func executePlaylist(app *App) {
+	configHandler, err := app.Stations()
 	if err != nil {
 		utils.Logger.Fatalf("Error loading config: %v", err)
 	}
 
-	store, err := storage.NewStorage(storageType, storagePath)
-	if err != nil {
-		utils.Logger.Fatalf("Error initializing storage: %v", err)
-	}
-
-	err = store.Init()
-	if err != nil {
-		utils.Logger.Fatalf("Error initializing storage: %v", err)
-	}
-
-	spotifyService, err := spotify.NewSpotifyService( configHandler, store)
-	utils.Logger.Infof("Updating Spotify playlist for range: %s", playlistRange)
+	spotifyService, err := app.Spotify()
+	utils.Logger.Infof("Updating Spotify playlist for range: %s", app.Config.PlaylistRange)
 	if err != nil {
 		utils.Logger.Fatalf("Error initializing Spotify service: %v", err)
 	}
 
-	if stationID == "" {
+	if app.Config.StationID == "" {
 		configStations := configHandler.GetAllStations()
 		if len(configStations) == 0 {
 			utils.Logger.Fatalf("No stations found in config")
@@ -54,19 +45,19 @@ func executePlaylist() {
 		for _, station := range configStations {
 			go func(stationID string) {
 				defer wg.Done()
-				updateStation(spotifyService, stationID)
+				updateStation(spotifyService, stationID, app.Config.PlaylistRange)
 			}(station.ID)
 		}
 		wg.Wait()
 	} else {
-		updateStation(spotifyService, stationID)
+		updateStation(spotifyService, app.Config.StationID, app.Config.PlaylistRange)
 	}
 
 }
 
-func updateStation(spotifyService *spotify.SpotifyService, stationID string) {
+func updateStation(spotifyService *spotify.SpotifyService, stationID, playlistRange string) {
 	utils.Logger.Infof("Updating Spotify playlist for station: %s", stationID)
-	err := spotifyService.UpdateSpotifyPlaylist(stationID, playlistRange)
+	err := spotifyService.UpdateSpotifyPlaylist(context.Background(), stationID, playlistRange)
 	if err != nil {
 		utils.Logger.Errorf("Error updating Spotify playlist for station %s: %v", stationID, err)
 	} else {
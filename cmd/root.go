@@ -4,25 +4,34 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
-	"radio-to-spotify/storage"
+	"radio-to-spotify/utils"
 )
 
 var (
-	logger      = logrus.New()
-	storageType string
-	storagePath string
-	logLevel    string
-	stationFile string
-	stationID   string
+	configFile string
+	app        *App // Created before any command runs
+
+	// Config keys of the flags of each command, bound when the command runs so commands can share flag names
+	flagBindings = make(map[*cobra.Command]map[string]string)
+	// Flags bound to each config key for the running command
+	boundFlags = make(map[string]*pflag.Flag)
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "radio-to-spotify",
 	Short: "Radio to Spotify is a tool to fetch now playing songs from radio stations and create Spotify playlists.",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		app = newApp(initConfig(cmd))
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if err := app.Close(); err != nil {
+			utils.Logger.Errorf("Error closing storage: %v", err)
+		}
+	},
 }
 
 func Execute() {
@@ -33,57 +42,54 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
-
 	// Define flags
-	rootCmd.PersistentFlags().StringVar(&storageType, "storage", "", "Storage type: file, sqlite, or postgres")
-	rootCmd.PersistentFlags().StringVar(&storagePath, "storage-path", "./data/", "Path to storage file or database connection string")
-	rootCmd.PersistentFlags().StringVar(&logLevel, "loglevel", "", "Logging level: debug, info, warn, error, fatal, panic")
-	rootCmd.PersistentFlags().StringVar(&stationFile, "station-file", "stations.json", "Path to stations file")
-	rootCmd.PersistentFlags().StringVar(&stationID, "station", "", "Station ID to fetch/store now playing")
-
-	// Bind flags to Viper
-	viper.BindPFlag("storage", rootCmd.PersistentFlags().Lookup("storage"))
-	viper.BindPFlag("storage_path", rootCmd.PersistentFlags().Lookup("storage-path"))
-	viper.BindPFlag("log_level", rootCmd.PersistentFlags().Lookup("loglevel"))
-	viper.BindPFlag("station_file", rootCmd.PersistentFlags().Lookup("station-file"))
-	viper.BindPFlag("station_id", rootCmd.PersistentFlags().Lookup("station"))
-
-	// Set up Viper to read from environment variables
-	viper.SetEnvPrefix("RTS") // Will look for environment variables with the prefix RTS_
-	viper.AutomaticEnv()
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Path to the config file (default data/config.toml)")
+	rootCmd.PersistentFlags().String("storage", "sqlite", "Storage type: file, sqlite, or postgres")
+	rootCmd.PersistentFlags().String("storage-path", "data", "Path to storage file or database connection string")
+	rootCmd.PersistentFlags().String("loglevel", "info", "Logging level: debug, info, warn, error")
+	rootCmd.PersistentFlags().String("station-file", "stations.json", "Path to stations file (JSON, YAML or TOML) or a directory of stations files")
+	rootCmd.PersistentFlags().String("station", "", "Station ID to fetch/store now playing")
+
+	bindFlags(rootCmd, map[string]string{
+		"storage":      "storage",
+		"storage_path": "storage-path",
+		"log_level":    "loglevel",
+		"station_file": "station-file",
+		"station_id":   "station",
+	})
 }
 
-func initConfig() {
-	// Set default values in case none are provided via environment or flags
-	viper.SetDefault("storage", "sqlite")
-	viper.SetDefault("storage_path", "data")
-	viper.SetDefault("log_level", "info")
-	viper.SetDefault("station_file", "stations.json")
-	viper.SetDefault("station_id", "")
-
-	// Read configuration from file if available (optional)
-	viper.SetConfigName("config") // Name of config file (without extension)
-	viper.SetConfigType("toml")   // Specify the config file format (could be json, toml, etc.)
-	viper.AddConfigPath("data")   // Look for the config file in the current directory
+// bindFlags registers the config keys of the flags of a command
+func bindFlags(cmd *cobra.Command, bindings map[string]string) {
+	flagBindings[cmd] = bindings
+}
 
-	// Attempt to read in the config file
-	if err := viper.ReadInConfig(); err != nil {
-		logger.Infof("No config file found, using environment variables and defaults: %v", err)
+// initConfig binds the flags of the running command and its parents to their config keys and loads the config
+func initConfig(cmd *cobra.Command) *utils.AppConfig {
+	for c := cmd; c != nil; c = c.Parent() {
+		for key, name := range flagBindings[c] {
+			flag := c.Flags().Lookup(name)
+			if flag == nil {
+				flag = c.PersistentFlags().Lookup(name)
+			}
+			if _, bound := boundFlags[key]; bound || flag == nil {
+				continue // The flag of the innermost command wins
+			}
+			viper.BindPFlag(key, flag)
+			boundFlags[key] = flag
+		}
 	}
 
-	// Initialize storage using Viper configuration
-	storageType = viper.GetString("storage")
-	storagePath = viper.GetString("storage_path")
-	store, err := storage.NewStorage(storageType, storagePath)
+	utils.SetupViper(viper.GetViper(), configFile)
+	config, err := utils.LoadAppConfig(viper.GetViper())
 	if err != nil {
-		logger.Fatalf("Error initializing storage: %v", err)
+		// Printed instead of logged, so each problem of an invalid config is on its own line
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
 	}
 
-	err = store.Init()
-	if err != nil {
-		logger.Fatalf("Error initializing storage: %v", err)
+	if file := viper.ConfigFileUsed(); file != "" {
+		utils.Logger.Debugf("Using config file %s", file)
 	}
-
-	logger.Infof("Using storage type: %s, path: %s", storageType, storagePath)
+	return config
 }
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"radio-to-spotify/scraper"
+	"radio-to-spotify/utils"
+)
+
+// Fraction of the interval by which each wait is randomly shortened or lengthened
+const fetchJitter = 0.1
+
+// stationWorker polls a single station on its own interval
+type stationWorker struct {
+	station  utils.Station
+	interval time.Duration
+	schedule *adaptiveSchedule // nil unless running with --adaptive
+	ctx      context.Context   // Cancelled to stop the worker and abort its running fetch
+	cancel   context.CancelFunc
+	done     chan struct{} // Closed when the worker returned
+}
+
+// stationInterval returns the fetch interval of a station, falling back to the default
+func (s *ScraperService) stationInterval(station *utils.Station) time.Duration {
+	if station.FetchInterval > 0 {
+		return time.Duration(station.FetchInterval)
+	}
+	return s.FetchInterval
+}
+
+// scheduledStations returns the enabled stations the daemon polls, honouring the --station flag
+func (s *ScraperService) scheduledStations() []utils.Station {
+	if s.StationID != "" {
+		station, err := s.configHandler.GetStationByID(s.StationID)
+		if err != nil {
+			utils.Logger.Errorf("Error getting station %s: %v", s.StationID, err)
+			return nil
+		}
+		if station.Disabled {
+			utils.Logger.Warnf("Station %s is disabled", s.StationID)
+			return nil
+		}
+		return []utils.Station{*station}
+	}
+	return s.configHandler.GetEnabledStations()
+}
+
+// healthFetchInterval returns the longest time the health check expects between two fetches: the shortest interval of
+// the running workers, or 0 if no station is polled. It follows the workers as stations are reloaded.
+func (s *ScraperService) healthFetchInterval() time.Duration {
+	s.workersMu.Lock()
+	defer s.workersMu.Unlock()
+
+	var minInterval time.Duration
+	for _, worker := range s.workers {
+		if minInterval == 0 || worker.interval < minInterval {
+			minInterval = worker.interval
+		}
+	}
+	if s.Adaptive && minInterval > 0 {
+		// Stations may sleep up to the max interval while waiting for the next song
+		minInterval = max(minInterval, s.AdaptiveMaxInterval)
+	}
+	return minInterval
+}
+
+func (s *ScraperService) startWorkers() {
+	s.workersMu.Lock()
+	defer s.workersMu.Unlock()
+
+	for _, station := range s.scheduledStations() {
+		s.startWorker(station)
+	}
+	utils.Logger.Infof("Started polling %d stations", len(s.workers))
+}
+
+// startWorker starts polling a station, replacing its current worker. The current worker has returned before the new
+// one starts, so a station is never fetched and stored twice at once. Must be called with workersMu held.
+func (s *ScraperService) startWorker(station utils.Station) {
+	s.stopWorker(station.ID)
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	worker := &stationWorker{
+		station:  station,
+		interval: s.stationInterval(&station),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	if s.Adaptive {
+		worker.schedule = newAdaptiveSchedule(s.AdaptiveMinInterval, s.AdaptiveMaxInterval)
+	}
+	s.workers[station.ID] = worker
+
+	go s.runWorker(worker)
+}
+
+// stopWorker stops polling a station and waits for its worker to return. Must be called with workersMu held, the
+// workers don't take it.
+func (s *ScraperService) stopWorker(id string) {
+	if worker, ok := s.workers[id]; ok {
+		worker.cancel()
+		<-worker.done
+		delete(s.workers, id)
+	}
+}
+
+// reconcileWorkers starts polling new and enabled stations, stops polling removed and disabled ones, and restarts
+// the workers of changed stations
+func (s *ScraperService) reconcileWorkers() {
+	s.workersMu.Lock()
+	defer s.workersMu.Unlock()
+
+	wanted := make(map[string]utils.Station)
+	for _, station := range s.scheduledStations() {
+		wanted[station.ID] = station
+	}
+
+	// Collect the changes first, starting and stopping workers changes s.workers
+	var removed []string
+	var changed, added []utils.Station
+	for id, worker := range s.workers {
+		station, ok := wanted[id]
+		switch {
+		case !ok:
+			removed = append(removed, id)
+		case !reflect.DeepEqual(worker.station, station):
+			changed = append(changed, station)
+		}
+		delete(wanted, id)
+	}
+	for _, station := range wanted {
+		added = append(added, station)
+	}
+
+	for _, id := range removed {
+		utils.Logger.Infof("Stopped polling station %s", id)
+		s.stopWorker(id)
+	}
+	for _, station := range changed {
+		utils.Logger.Infof("Rescheduling changed station %s", station.ID)
+		s.startWorker(station)
+	}
+	for _, station := range added {
+		utils.Logger.Infof("Started polling station %s", station.ID)
+		s.startWorker(station)
+	}
+
+	utils.Logger.Infof("Reloaded stations: %d added, %d removed, %d changed, polling %d stations", len(added), len(removed), len(changed), len(s.workers))
+}
+
+func (s *ScraperService) stopWorkers() {
+	s.workersMu.Lock()
+	defer s.workersMu.Unlock()
+
+	// Cancel all workers before waiting, so they stop in parallel
+	for _, worker := range s.workers {
+		worker.cancel()
+	}
+	for id := range s.workers {
+		s.stopWorker(id)
+	}
+}
+
+func (s *ScraperService) runWorker(worker *stationWorker) {
+	defer close(worker.done)
+	utils.Logger.Debugf("Polling station %s every %v", worker.station.ID, worker.interval)
+
+	// Start at a random point within the first interval so stations don't all fire at once
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(worker.interval))))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			song := s.fetchStation(worker.ctx, &worker.station)
+			timer.Reset(worker.nextDelay(song))
+		case <-worker.ctx.Done():
+			return
+		}
+	}
+}
+
+// nextDelay returns the time until the next fetch, predicted from the current song in adaptive mode
+func (w *stationWorker) nextDelay(song *scraper.Song) time.Duration {
+	if w.schedule == nil {
+		return jitter(w.interval)
+	}
+	delay := w.schedule.next(song, time.Now(), jitter(w.interval))
+	utils.Logger.Debugf("Next fetch of station %s in %v", w.station.ID, delay.Round(time.Second))
+	return delay
+}
+
+// jitter randomly shortens or lengthens an interval by up to fetchJitter
+func jitter(interval time.Duration) time.Duration {
+	spread := int64(float64(interval) * fetchJitter)
+	if spread <= 0 {
+		return interval
+	}
+	return interval - time.Duration(spread) + time.Duration(rand.Int63n(2*spread+1))
+}
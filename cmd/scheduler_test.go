@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"radio-to-spotify/utils"
+)
+
+func TestStartWorkerReplacesRunningWorker(t *testing.T) {
+	// The station never answers, so each worker blocks in its first fetch until it is cancelled
+	requests := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &ScraperService{
+		FetchInterval: time.Millisecond,
+		ctx:           ctx,
+		cancel:        cancel,
+		workers:       make(map[string]*stationWorker),
+	}
+	station := utils.Station{ID: "replaced-worker", Type: "json", URL: server.URL, ArtistKey: []interface{}{"artist"}, TitleKey: []interface{}{"title"}}
+
+	s.workersMu.Lock()
+	s.startWorker(station)
+	old := s.workers[station.ID]
+	s.workersMu.Unlock()
+	waitForRequest(t, requests)
+
+	station.Name = "Changed"
+	s.workersMu.Lock()
+	s.startWorker(station)
+	replacement := s.workers[station.ID]
+	s.workersMu.Unlock()
+
+	select {
+	case <-old.done:
+	default:
+		t.Fatal("replacement started before the old worker returned")
+	}
+	if replacement == old || replacement.ctx.Err() != nil {
+		t.Fatal("replacement worker is not running")
+	}
+	waitForRequest(t, requests)
+
+	s.stopWorkers()
+	select {
+	case <-replacement.done:
+	default:
+		t.Fatal("stopWorkers returned before the worker")
+	}
+	if len(s.workers) != 0 {
+		t.Errorf("%d workers left after stopping", len(s.workers))
+	}
+}
+
+func waitForRequest(t *testing.T, requests <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker didn't fetch the station")
+	}
+}
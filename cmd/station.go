@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"radio-to-spotify/scraper"
+	"radio-to-spotify/spotify"
+	"radio-to-spotify/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	definitionFile       string
+	stationOverride      utils.Station
+	artistKeyJSON        string
+	titleKeyJSON         string
+	stationFetchInterval time.Duration
+	snippetLength        int
+	spotifyPreview       bool
+	discoverArtist       string
+	discoverTitle        string
+	discoverStation      utils.Station
+)
+
+// addStationFlags adds the flags to give a station definition from a file or stdin, and to override its fields
+func addStationFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&definitionFile, "definition", "", "Path to a JSON station definition, or - to read it from stdin")
+	cmd.Flags().StringVar(&stationOverride.Name, "name", "", "Display name of the station")
+	cmd.Flags().StringVar(&stationOverride.Type, "type", "", "Scraper type, e.g. html, json, xml, plaintext, icy")
+	cmd.Flags().StringVar(&stationOverride.URL, "url", "", "URL to scrape")
+	cmd.Flags().StringVar(&stationOverride.ArtistTag, "artist-tag", "", "CSS selector of the artist (html)")
+	cmd.Flags().StringVar(&stationOverride.TitleTag, "title-tag", "", "CSS selector of the title (html)")
+	cmd.Flags().StringVar(&artistKeyJSON, "artist-key", "", `JSON key path of the artist, e.g. '["songs", "*", "artist"]' (json)`)
+	cmd.Flags().StringVar(&titleKeyJSON, "title-key", "", `JSON key path of the title, e.g. '["songs", "*", "title"]' (json)`)
+	cmd.Flags().StringVar(&stationOverride.ArtistXPath, "artist-xpath", "", "XPath of the artist (xml)")
+	cmd.Flags().StringVar(&stationOverride.TitleXPath, "title-xpath", "", "XPath of the title (xml)")
+	cmd.Flags().StringVar(&stationOverride.Regex, "regex", "", "Regex with artist and title groups (plaintext, icy)")
+	cmd.Flags().StringVar(&stationOverride.Separator, "separator", "", "Separator between artist and title (icy)")
+	cmd.Flags().DurationVar(&stationFetchInterval, "fetch-interval", 0, "Interval between scrapes of the station (e.g., 30s, 1m)")
+	cmd.Flags().StringVar(&stationOverride.PlaylistID, "playlist-id", "", "Spotify playlist ID of the station")
+}
+
+func init() {
+	addStationFlags(stationTestCmd)
+	stationTestCmd.Flags().IntVar(&snippetLength, "snippet-length", 500, "Number of bytes of the raw response to print (0 prints everything)")
+	stationTestCmd.Flags().BoolVar(&spotifyPreview, "spotify", false, "Search Spotify for the scraped songs and print the best match with its score")
+
+	stationDiscoverCmd.Flags().StringVar(&discoverStation.URL, "url", "", "URL of the now playing page or feed")
+	stationDiscoverCmd.Flags().StringVar(&discoverArtist, "artist", "", "Artist currently playing on the station")
+	stationDiscoverCmd.Flags().StringVar(&discoverTitle, "title", "", "Title currently playing on the station")
+	stationDiscoverCmd.Flags().StringVar(&discoverStation.ID, "id", "new_station", "ID of the proposed station")
+	stationDiscoverCmd.Flags().StringVar(&discoverStation.Name, "name", "", "Name of the proposed station (defaults to the ID)")
+	stationDiscoverCmd.Flags().StringVar(&discoverStation.UserAgent, "user-agent", "", "User-Agent to send instead of the default")
+	stationDiscoverCmd.MarkFlagRequired("url")
+	stationDiscoverCmd.MarkFlagRequired("artist")
+	stationDiscoverCmd.MarkFlagRequired("title")
+
+	stationCmd.AddCommand(stationTestCmd)
+	stationCmd.AddCommand(stationDiscoverCmd)
+	rootCmd.AddCommand(stationCmd)
+}
+
+var stationCmd = &cobra.Command{
+	Use:   "station",
+	Short: "Work with station definitions",
+}
+
+var stationTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Scrape a station definition once and print what was received and matched",
+	Long: `Scrape a station definition once and print what was received and matched.
+The definition is read from --definition, or from the --station entry of the stations file, and the field flags
+are applied on top of it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		executeStationTest(app, cmd)
+	},
+}
+
+var stationDiscoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Find selectors, key paths or regexes for a new station from the song it is currently playing",
+	Run: func(cmd *cobra.Command, args []string) {
+		executeStationDiscover()
+	},
+}
+
+func executeStationTest(app *App, cmd *cobra.Command) {
+	station, err := loadStationDefinition(app, cmd)
+	if err != nil {
+		utils.Logger.Fatalf("Error loading station definition: %v", err)
+	}
+
+	if err := utils.StationValidator(station); err != nil {
+		utils.Logger.Fatalf("Invalid station definition: %v", err)
+	}
+
+	trace, err := scraper.TraceStation(context.Background(), *station)
+	if err != nil {
+		utils.Logger.Fatalf("Error creating scraper: %v", err)
+	}
+
+	printTrace(station, trace)
+
+	if spotifyPreview && len(trace.Songs) > 0 {
+		printSpotifyPreview(app, trace.Songs)
+	}
+
+	if trace.Err != nil {
+		os.Exit(1)
+	}
+}
+
+// loadStationDefinition reads the station to test from --definition or the stations file and applies the field flags
+func loadStationDefinition(app *App, cmd *cobra.Command) (*utils.Station, error) {
+	station := &utils.Station{}
+	if definitionFile == "" && app.Config.StationID != "" {
+		configHandler, err := app.Stations()
+		if err != nil {
+			return nil, err
+		}
+		station, err = configHandler.GetStationByID(app.Config.StationID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyStationFlags(cmd, station); err != nil {
+		return nil, err
+	}
+
+	if station.ID == "" {
+		station.ID = "test"
+	}
+	if station.Name == "" {
+		station.Name = station.ID
+	}
+	if station.URL == "" || station.Type == "" {
+		return nil, fmt.Errorf("no station definition given, use --definition, --station or --type and --url")
+	}
+
+	return station, nil
+}
+
+// applyStationFlags replaces the station with the --definition, if given, and overrides the fields set by flags
+func applyStationFlags(cmd *cobra.Command, station *utils.Station) error {
+	if definitionFile != "" {
+		definition, err := readStationDefinition(definitionFile)
+		if err != nil {
+			return err
+		}
+		if definition.ID == "" {
+			definition.ID = station.ID
+		}
+		*station = *definition
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("name") {
+		station.Name = stationOverride.Name
+	}
+	if flags.Changed("type") {
+		station.Type = stationOverride.Type
+	}
+	if flags.Changed("url") {
+		station.URL = stationOverride.URL
+	}
+	if flags.Changed("artist-tag") {
+		station.ArtistTag = stationOverride.ArtistTag
+	}
+	if flags.Changed("title-tag") {
+		station.TitleTag = stationOverride.TitleTag
+	}
+	if flags.Changed("artist-key") {
+		if err := json.Unmarshal([]byte(artistKeyJSON), &station.ArtistKey); err != nil {
+			return fmt.Errorf("invalid --artist-key: %v", err)
+		}
+	}
+	if flags.Changed("title-key") {
+		if err := json.Unmarshal([]byte(titleKeyJSON), &station.TitleKey); err != nil {
+			return fmt.Errorf("invalid --title-key: %v", err)
+		}
+	}
+	if flags.Changed("artist-xpath") {
+		station.ArtistXPath = stationOverride.ArtistXPath
+	}
+	if flags.Changed("title-xpath") {
+		station.TitleXPath = stationOverride.TitleXPath
+	}
+	if flags.Changed("regex") {
+		station.Regex = stationOverride.Regex
+	}
+	if flags.Changed("separator") {
+		station.Separator = stationOverride.Separator
+	}
+	if flags.Changed("fetch-interval") {
+		station.FetchInterval = utils.Duration(stationFetchInterval)
+	}
+	if flags.Changed("playlist-id") {
+		station.PlaylistID = stationOverride.PlaylistID
+	}
+	return nil
+}
+
+// readStationDefinition decodes a single JSON station from a file, or from stdin if path is -
+func readStationDefinition(path string) (*utils.Station, error) {
+	station := &utils.Station{}
+	if path == "-" {
+		if err := json.NewDecoder(os.Stdin).Decode(station); err != nil {
+			return nil, fmt.Errorf("error decoding definition from stdin: %v", err)
+		}
+		return station, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, station); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %v", path, err)
+	}
+	return station, nil
+}
+
+func printTrace(station *utils.Station, trace *scraper.Trace) {
+	fmt.Printf("Station:  %s (%s), type %s\n", station.Name, station.ID, station.Type)
+	fmt.Printf("URL:      %s\n", station.URL)
+	if trace.StatusCode != 0 {
+		fmt.Printf("Status:   %d\n", trace.StatusCode)
+	}
+	fmt.Printf("Duration: %v\n", trace.Duration.Round(time.Millisecond))
+
+	if trace.Body != nil {
+		fmt.Printf("\nResponse (%d bytes):\n%s\n", len(trace.Body), snippet(trace.Body, snippetLength))
+	}
+
+	if len(trace.Matches) > 0 {
+		fmt.Println("\nMatches:")
+		for _, match := range trace.Matches {
+			fmt.Printf("  %s %s: %d value(s)\n", match.Field, match.Selector, len(match.Values))
+			for i, value := range match.Values {
+				fmt.Printf("    [%d] %q\n", i, value)
+			}
+		}
+	}
+
+	if len(trace.Songs) > 0 {
+		fmt.Printf("\nSongs (%d, newest first):\n", len(trace.Songs))
+		for _, song := range trace.Songs {
+			data, err := json.Marshal(song)
+			if err != nil {
+				data = []byte(fmt.Sprintf("%s - %s", song.Artist, song.Title))
+			}
+			fmt.Printf("  %s\n", data)
+		}
+	}
+
+	if trace.Err != nil {
+		fmt.Printf("\nError: %v\n", trace.Err)
+	}
+}
+
+// snippet returns at most length bytes of the body, without cutting a UTF-8 character in half
+func snippet(body []byte, length int) string {
+	if length <= 0 || len(body) <= length {
+		return string(body)
+	}
+	for length > 0 && !utf8.RuneStart(body[length]) {
+		length--
+	}
+	return string(body[:length]) + fmt.Sprintf("\n... (%d more bytes)", len(body)-length)
+}
+
+func printSpotifyPreview(app *App, songs []*scraper.Song) {
+	// Searching needs neither the stations nor the storage
+	spotifyService, err := spotify.NewSpotifyService(app.Config.Spotify, nil, nil, app.Cache())
+	if err != nil {
+		fmt.Printf("\nSpotify: %v\n", err)
+		return
+	}
+
+	fmt.Println("\nSpotify:")
+	for _, song := range songs {
+		candidate, err := spotifyService.SearchTrack(context.Background(), *song)
+		switch {
+		case err != nil:
+			fmt.Printf("  %s - %s: %v\n", song.Artist, song.Title, err)
+		case candidate == nil:
+			fmt.Printf("  %s - %s: no match\n", song.Artist, song.Title)
+		default:
+			var artists []string
+			for _, artist := range candidate.Track.Artists {
+				artists = append(artists, artist.Name)
+			}
+			note := ""
+			if candidate.Score < spotifyService.MinScore() {
+				note = ", below the minimum score, no match"
+			}
+			fmt.Printf("  %s - %s: %s - %s (%s, score %.2f%s)\n", song.Artist, song.Title, strings.Join(artists, ", "),
+				candidate.Track.Name, candidate.Track.ID, candidate.Score, note)
+		}
+	}
+}
+
+func executeStationDiscover() {
+	if discoverStation.Name == "" {
+		discoverStation.Name = discoverStation.ID
+	}
+
+	candidates, err := scraper.Discover(context.Background(), discoverStation, discoverArtist, discoverTitle)
+	if err != nil {
+		utils.Logger.Fatalf("Error discovering station: %v", err)
+	}
+	if len(candidates) == 0 {
+		fmt.Printf("Could not find %q and %q in %s\n", discoverArtist, discoverTitle, discoverStation.URL)
+		os.Exit(1)
+	}
+
+	// Selectors and regexes are easier to read without escaped < and >
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	for i, candidate := range candidates {
+		newest := candidate.Songs[0]
+		fmt.Printf("Candidate %d: %s, %d song(s), newest: %s - %s\n", i+1, candidate.Station.Type, len(candidate.Songs), newest.Artist, newest.Title)
+		if err := encoder.Encode(candidate.Station); err != nil {
+			utils.Logger.Fatalf("Error encoding station: %v", err)
+		}
+		fmt.Println()
+	}
+}
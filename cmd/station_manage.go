@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"radio-to-spotify/utils"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	addStationFlags(stationAddCmd)
+	addStationFlags(stationEditCmd)
+
+	stationCmd.AddCommand(stationListCmd)
+	stationCmd.AddCommand(stationAddCmd)
+	stationCmd.AddCommand(stationEditCmd)
+	stationCmd.AddCommand(stationRemoveCmd)
+	stationCmd.AddCommand(stationEnableCmd)
+	stationCmd.AddCommand(stationDisableCmd)
+}
+
+var stationListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the stations of the stations file",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		executeStationList(app)
+	},
+}
+
+var stationAddCmd = &cobra.Command{
+	Use:   "add <id>",
+	Short: "Add a station to the stations file",
+	Long: `Add a station to the stations file. The station is read from --definition and the field flags are applied
+on top of it. The stations file is only changed if it is still valid with the new station.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		executeStationAdd(app, cmd, args[0])
+	},
+}
+
+var stationEditCmd = &cobra.Command{
+	Use:   "edit <id>",
+	Short: "Change a station in the stations file",
+	Long: `Change a station in the stations file. --definition replaces the whole station, the field flags only
+change the given fields. The stations file is only changed if it is still valid afterwards.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		executeStationEdit(app, cmd, args[0])
+	},
+}
+
+var stationRemoveCmd = &cobra.Command{
+	Use:   "remove <id>",
+	Short: "Remove a station from the stations file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		executeStationRemove(app, args[0])
+	},
+}
+
+var stationEnableCmd = &cobra.Command{
+	Use:   "enable <id>",
+	Short: "Enable fetching a disabled station",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		executeStationSetDisabled(app, args[0], false)
+	},
+}
+
+var stationDisableCmd = &cobra.Command{
+	Use:   "disable <id>",
+	Short: "Stop fetching a station without removing it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		executeStationSetDisabled(app, args[0], true)
+	},
+}
+
+func loadConfigHandler(app *App) *utils.ConfigHandler {
+	configHandler, err := app.Stations()
+	if err != nil {
+		exitOnStationError(app, "Error loading config", err)
+	}
+	return configHandler
+}
+
+// exitOnStationError prints all problems of a validation error, or logs any other error, and exits
+func exitOnStationError(app *App, message string, err error) {
+	var validationErr *utils.ValidationError
+	if !errors.As(err, &validationErr) {
+		utils.Logger.Fatalf("%s: %v", message, err)
+	}
+
+	fmt.Printf("%s, %d problem(s) in %s:\n", message, len(validationErr.Problems), app.Config.StationFile)
+	for _, problem := range validationErr.Problems {
+		fmt.Printf("  - %s\n", problem)
+	}
+	os.Exit(1)
+}
+
+func executeStationList(app *App) {
+	configHandler := loadConfigHandler(app)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tNAME\tTYPE\tINTERVAL\tSTATUS\tURL")
+	for _, station := range configHandler.GetAllStations() {
+		interval := "default"
+		if station.FetchInterval > 0 {
+			interval = time.Duration(station.FetchInterval).String()
+		}
+		status := "enabled"
+		if station.Disabled {
+			status = "disabled"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", station.ID, station.Name, station.Type, interval, status, station.URL)
+	}
+	writer.Flush()
+}
+
+func executeStationAdd(app *App, cmd *cobra.Command, id string) {
+	configHandler := loadConfigHandler(app)
+
+	station := &utils.Station{ID: id}
+	if err := applyStationFlags(cmd, station); err != nil {
+		utils.Logger.Fatalf("Error reading station definition: %v", err)
+	}
+	station.ID = id
+	if station.Name == "" {
+		station.Name = id
+	}
+
+	if err := configHandler.AddStation(station); err != nil {
+		exitOnStationError(app, "Error adding station", err)
+	}
+	fmt.Printf("Added station %s\n", id)
+}
+
+func executeStationEdit(app *App, cmd *cobra.Command, id string) {
+	configHandler := loadConfigHandler(app)
+
+	existing, err := configHandler.GetStationByID(id)
+	if err != nil {
+		utils.Logger.Fatalf("Error getting station %s: %v", id, err)
+	}
+
+	station := *existing
+	if err := applyStationFlags(cmd, &station); err != nil {
+		utils.Logger.Fatalf("Error reading station definition: %v", err)
+	}
+	station.ID = id
+
+	if err := configHandler.UpdateStation(&station); err != nil {
+		exitOnStationError(app, "Error updating station", err)
+	}
+	fmt.Printf("Updated station %s\n", id)
+}
+
+func executeStationRemove(app *App, id string) {
+	configHandler := loadConfigHandler(app)
+
+	if err := configHandler.RemoveStation(id); err != nil {
+		exitOnStationError(app, "Error removing station", err)
+	}
+	fmt.Printf("Removed station %s, its stored songs are kept\n", id)
+}
+
+func executeStationSetDisabled(app *App, id string, disabled bool) {
+	configHandler := loadConfigHandler(app)
+
+	existing, err := configHandler.GetStationByID(id)
+	if err != nil {
+		utils.Logger.Fatalf("Error getting station %s: %v", id, err)
+	}
+
+	station := *existing
+	station.Disabled = disabled
+	if err := configHandler.UpdateStation(&station); err != nil {
+		exitOnStationError(app, "Error updating station", err)
+	}
+
+	if disabled {
+		fmt.Printf("Disabled station %s\n", id)
+	} else {
+		fmt.Printf("Enabled station %s\n", id)
+	}
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+
 	"radio-to-spotify/scraper"
 	"radio-to-spotify/storage"
 	"radio-to-spotify/utils"
@@ -19,44 +21,40 @@ var storeCmd = &cobra.Command{
 	Use:   "store",
 	Short: "Store now playing songs from radio stations",
 	Run: func(cmd *cobra.Command, args []string) {
-		executeStore()
+		executeStore(app)
 	},
 }
 
-func executeStore() {
-	configHandler, err := utils.NewConfigHandler(stationFile)
+func executeStore(app *App) {
+	configHandler, err := app.Stations()
 	if err != nil {
 		utils.Logger.Fatalf("Error loading config: %v", err)
 	}
 
-	store, err := storage.NewStorage(storageType, storagePath)
-	if err != nil {
-		utils.Logger.Fatalf("Error initializing storage: %v", err)
-	}
-
-	err = store.Init()
+	store, err := app.Storage()
 	if err != nil {
 		utils.Logger.Fatalf("Error initializing storage: %v", err)
 	}
 
-	stations, songs, err := scraper.FetchNowPlaying(configHandler, stationID)
+	stations, histories, err := scraper.FetchHistory(context.Background(), configHandler, app.Config.StationID)
 	if err != nil {
 		utils.Logger.Fatalf("Error fetching now playing: %v", err)
 	}
 
 	for i, station := range stations {
+		song := histories[i][0]
 		if storeDryRun {
-			utils.Logger.Infof("Dry run: would store song for station %s: %s - %s\n", station.ID, songs[i].Artist, songs[i].Title)
+			utils.Logger.Infof("Dry run: would store song for station %s: %s - %s\n", station.ID, song.Artist, song.Title)
 		} else {
-			changed, err := store.StoreNowPlaying(station.ID, songs[i])
+			stored, err := storage.StoreHistory(context.Background(), store, station.ID, histories[i])
 			if err != nil {
 				utils.Logger.Fatalf("Error storing now playing for station %s: %v", station.ID, err)
 			}
-			if changed {
-				utils.Logger.Infof("Stored song for station %s: %s - %s\n", station.ID, songs[i].Artist, songs[i].Title)
+			if stored > 0 {
+				utils.Logger.Infof("Stored %d song(s) for station %s: %s - %s\n", stored, station.ID, song.Artist, song.Title)
 
 			} else {
-				utils.Logger.Infof("Song hasn't changed for station %s: %s - %s\n", station.ID, songs[i].Artist, songs[i].Title)
+				utils.Logger.Infof("Song hasn't changed for station %s: %s - %s\n", station.ID, song.Artist, song.Title)
 			}
 		}
 	}
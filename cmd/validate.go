@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"radio-to-spotify/utils"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the stations file and report all problems",
+	Run: func(cmd *cobra.Command, args []string) {
+		executeValidate(app)
+	},
+}
+
+func executeValidate(app *App) {
+	configHandler, err := app.Stations()
+	if err != nil {
+		var validationErr *utils.ValidationError
+		if !errors.As(err, &validationErr) {
+			utils.Logger.Fatalf("Error loading config: %v", err)
+		}
+
+		fmt.Printf("%s has %d problem(s):\n", app.Config.StationFile, len(validationErr.Problems))
+		for _, problem := range validationErr.Problems {
+			fmt.Printf("  - %s\n", problem)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s is valid (%d stations)\n", app.Config.StationFile, len(configHandler.GetAllStations()))
+}
@@ -0,0 +1,190 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"radio-to-spotify/utils"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRetries          = 2
+	DefaultRetryBackoff     = 1 * time.Second
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 5 * time.Minute
+
+	maxRetryBackoff = 30 * time.Second
+)
+
+// ErrBreakerOpen is returned instead of fetching a station whose circuit breaker is open
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // Station is fetched normally
+	BreakerOpen     BreakerState = "open"      // Station is skipped until the cooldown has passed
+	BreakerHalfOpen BreakerState = "half-open" // Cooldown has passed, the next fetch probes the station
+)
+
+// circuitBreaker stops fetching a station after too many consecutive failures and probes it again after a cooldown
+type circuitBreaker struct {
+	mu        sync.Mutex
+	stationID string
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	threshold int
+	cooldown  time.Duration
+}
+
+var breakers = struct {
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}{breakers: make(map[string]*circuitBreaker)}
+
+// getBreaker returns the circuit breaker of a station, applying the current station config
+func getBreaker(station *utils.Station) *circuitBreaker {
+	breakers.mu.Lock()
+	defer breakers.mu.Unlock()
+
+	breaker, exists := breakers.breakers[station.ID]
+	if !exists {
+		breaker = &circuitBreaker{stationID: station.ID, state: BreakerClosed}
+		breakers.breakers[station.ID] = breaker
+	}
+
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	breaker.threshold = station.BreakerThreshold
+	if breaker.threshold <= 0 {
+		breaker.threshold = DefaultBreakerThreshold
+	}
+	breaker.cooldown = time.Duration(station.BreakerCooldown)
+	if breaker.cooldown <= 0 {
+		breaker.cooldown = DefaultBreakerCooldown
+	}
+	return breaker
+}
+
+// allow reports whether the station may be fetched. An open breaker becomes half-open once the cooldown has passed.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.transition(BreakerHalfOpen)
+	}
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	if b.state != BreakerClosed {
+		b.transition(BreakerClosed)
+	} else {
+		b.report()
+	}
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	switch {
+	case b.state == BreakerHalfOpen:
+		// The probe failed, wait for another cooldown
+		b.openedAt = time.Now()
+		b.transition(BreakerOpen)
+	case b.state == BreakerClosed && b.failures >= b.threshold:
+		b.openedAt = time.Now()
+		b.transition(BreakerOpen)
+	default:
+		b.report()
+	}
+}
+
+// transition changes the state, logs it and reports it to the health check. Must be called with the lock held.
+func (b *circuitBreaker) transition(state BreakerState) {
+	previous := b.state
+	b.state = state
+	switch state {
+	case BreakerOpen:
+		utils.Logger.Warnf("Circuit breaker for station %s %s -> %s after %d consecutive failures, retrying in %v", b.stationID, previous, state, b.failures, b.cooldown)
+	default:
+		utils.Logger.Infof("Circuit breaker for station %s %s -> %s", b.stationID, previous, state)
+	}
+	b.report()
+}
+
+func (b *circuitBreaker) report() {
+	utils.SetBreakerStatus(b.stationID, string(b.state), b.failures)
+}
+
+// retryBackoff returns the delay before the given retry (starting at 0): exponential with jitter, capped at maxRetryBackoff
+func retryBackoff(base time.Duration, attempt int) time.Duration {
+	delay := base << attempt
+	if delay <= 0 || delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	// Wait between half and the full delay so stations failing together don't retry together
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// fetchWithRetries gets the station history, retrying failed attempts with backoff until the context is done. Returns
+// ErrBreakerOpen without fetching while the circuit breaker of the station is open.
+func fetchWithRetries(ctx context.Context, station *utils.Station, scraperInstance Scraper) ([]*Song, error) {
+	breaker := getBreaker(station)
+	if !breaker.allow() {
+		return nil, ErrBreakerOpen
+	}
+
+	retries := DefaultRetries
+	if station.Retries != nil {
+		retries = *station.Retries
+	}
+	backoff := time.Duration(station.RetryBackoff)
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	var history []*Song
+	var err error
+	for attempt := 0; ; attempt++ {
+		history, err = scraperInstance.GetHistory(ctx)
+		if err == nil && len(history) == 0 {
+			err = errNoSongs
+		}
+		// Shutting down isn't a failure of the station, so the breaker is left alone
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil || attempt >= retries {
+			break
+		}
+
+		delay := retryBackoff(backoff, attempt)
+		utils.Logger.Debugf("Error fetching station %s (%s), retry %d/%d in %v: %v", station.Name, station.ID, attempt+1, retries, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	if err != nil {
+		breaker.failure()
+		return nil, err
+	}
+	breaker.success()
+	return history, nil
+}
@@ -0,0 +1,161 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"radio-to-spotify/utils"
+)
+
+// fakeScraper returns the results in order, repeating the last one
+type fakeScraper struct {
+	results []error
+	calls   int
+	onCall  func()
+}
+
+func (f *fakeScraper) GetHistory(ctx context.Context) ([]*Song, error) {
+	err := f.results[min(f.calls, len(f.results)-1)]
+	f.calls++
+	if f.onCall != nil {
+		f.onCall()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []*Song{{Artist: "Artist", Title: "Title"}}, nil
+}
+
+func (f *fakeScraper) GetNowPlaying(ctx context.Context) (*Song, error) {
+	return firstSong(f.GetHistory(ctx))
+}
+
+var errFetch = errors.New("fetch failed")
+
+func testStation(id string, retries int) *utils.Station {
+	return &utils.Station{
+		ID:               id,
+		Retries:          &retries,
+		RetryBackoff:     utils.Duration(time.Millisecond),
+		BreakerThreshold: 2,
+		BreakerCooldown:  utils.Duration(time.Minute),
+	}
+}
+
+func breakerState(t *testing.T, station *utils.Station) (BreakerState, int) {
+	t.Helper()
+	breaker := getBreaker(station)
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	return breaker.state, breaker.failures
+}
+
+// expireCooldown moves the time the breaker opened back, as if the cooldown had passed
+func expireCooldown(station *utils.Station) {
+	breaker := getBreaker(station)
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	breaker.openedAt = breaker.openedAt.Add(-time.Duration(station.BreakerCooldown))
+}
+
+func TestBreakerStates(t *testing.T) {
+	ctx := context.Background()
+	station := testStation("breaker-states", 0)
+	failing := &fakeScraper{results: []error{errFetch}}
+
+	steps := []struct {
+		name         string
+		scraper      *fakeScraper
+		expire       bool
+		wantErr      error
+		wantState    BreakerState
+		wantFailures int
+		wantCalls    int
+	}{
+		{name: "first failure", scraper: failing, wantErr: errFetch, wantState: BreakerClosed, wantFailures: 1, wantCalls: 1},
+		{name: "threshold reached", scraper: failing, wantErr: errFetch, wantState: BreakerOpen, wantFailures: 2, wantCalls: 2},
+		{name: "skipped while open", scraper: failing, wantErr: ErrBreakerOpen, wantState: BreakerOpen, wantFailures: 2, wantCalls: 2},
+		{name: "failed probe", scraper: failing, expire: true, wantErr: errFetch, wantState: BreakerOpen, wantFailures: 3, wantCalls: 3},
+		{name: "new cooldown", scraper: failing, wantErr: ErrBreakerOpen, wantState: BreakerOpen, wantFailures: 3, wantCalls: 3},
+		{name: "successful probe", scraper: &fakeScraper{results: []error{nil}}, expire: true, wantState: BreakerClosed, wantCalls: 1},
+	}
+
+	for _, step := range steps {
+		if step.expire {
+			expireCooldown(station)
+		}
+		_, err := fetchWithRetries(ctx, station, step.scraper)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: error = %v, want %v", step.name, err, step.wantErr)
+		}
+		state, failures := breakerState(t, station)
+		if state != step.wantState || failures != step.wantFailures || step.scraper.calls != step.wantCalls {
+			t.Fatalf("%s: state %s with %d failures after %d calls, want %s with %d after %d", step.name,
+				state, failures, step.scraper.calls, step.wantState, step.wantFailures, step.wantCalls)
+		}
+	}
+}
+
+func TestFetchWithRetries(t *testing.T) {
+	station := testStation("retries", 2)
+	// Two failures, the second an empty history, then a success
+	scraper := &fakeScraper{results: []error{errFetch, errNoSongs, nil}}
+
+	history, err := fetchWithRetries(context.Background(), station, scraper)
+	if err != nil {
+		t.Fatalf("fetchWithRetries: %v", err)
+	}
+	if len(history) != 1 || scraper.calls != 3 {
+		t.Errorf("got %d songs after %d calls, want 1 after 3", len(history), scraper.calls)
+	}
+	if state, failures := breakerState(t, station); state != BreakerClosed || failures != 0 {
+		t.Errorf("breaker %s with %d failures, want closed with 0", state, failures)
+	}
+}
+
+func TestFetchWithRetriesCancelled(t *testing.T) {
+	station := testStation("cancelled", 5)
+	station.RetryBackoff = utils.Duration(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	// Cancelled while waiting for the first retry
+	scraper := &fakeScraper{results: []error{errFetch}, onCall: func() { time.AfterFunc(10*time.Millisecond, cancel) }}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := fetchWithRetries(ctx, station, scraper)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetchWithRetries kept waiting after the context was cancelled")
+	}
+	if state, failures := breakerState(t, station); state != BreakerClosed || failures != 0 {
+		t.Errorf("breaker %s with %d failures, want the cancelled fetch not to count", state, failures)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{attempt: 0, min: 500 * time.Millisecond, max: time.Second},
+		{attempt: 2, min: 2 * time.Second, max: 4 * time.Second},
+		{attempt: 10, min: maxRetryBackoff / 2, max: maxRetryBackoff},
+		{attempt: 100, min: maxRetryBackoff / 2, max: maxRetryBackoff},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if delay := retryBackoff(time.Second, tt.attempt); delay < tt.min || delay > tt.max {
+				t.Fatalf("retryBackoff(1s, %d) = %v, want between %v and %v", tt.attempt, delay, tt.min, tt.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,447 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"radio-to-spotify/utils"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/xmlquery"
+)
+
+// Bytes of surrounding text kept as literal context in discovered regexes
+const discoverRegexContext = 30
+
+var (
+	cssIdentRegex = regexp.MustCompile(`^-?[A-Za-z_][A-Za-z0-9_-]*$`)
+	digitsRegex   = regexp.MustCompile(`[0-9]+`)
+)
+
+// Candidate is a discovered station definition and the songs it scraped from the sample response
+type Candidate struct {
+	Station utils.Station
+	Songs   []*Song
+}
+
+// Discover fetches the station URL and searches the response for the given artist and title. Every selector, key
+// path, XPath or regex that finds them is returned as a candidate station definition, based on the given station.
+// Candidates are checked by scraping the response with them, so each one finds the song at least once.
+func Discover(ctx context.Context, station utils.Station, artist, title string) ([]Candidate, error) {
+	artist, title = strings.TrimSpace(artist), strings.TrimSpace(title)
+	if artist == "" || title == "" {
+		return nil, fmt.Errorf("artist and title are required")
+	}
+
+	// A trace makes fetch skip the conditional cache and keep the body
+	base := NewBaseScraper(station)
+	trace := &Trace{}
+	base.setTrace(trace)
+	_, err := base.fetch(ctx, func(io.Reader) ([]*Song, error) {
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	body := trace.Body
+
+	var proposals []utils.Station
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case json.Valid(trimmed):
+		proposals = discoverJSON(body, artist, title)
+	case bytes.HasPrefix(trimmed, []byte("<?xml")) || bytes.Contains(trimmed, []byte("<rss")):
+		proposals = discoverXML(body, artist, title)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		proposals = discoverHTML(body, artist, title)
+	}
+	// Structured feeds are better scraped with their own type, so only fall back to a regex for them
+	if len(proposals) == 0 || proposals[0].Type == "html" {
+		proposals = append(proposals, discoverRegex(body, artist, title)...)
+	}
+
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	for _, proposal := range proposals {
+		candidate := station
+		candidate.Type = proposal.Type
+		candidate.ArtistTag, candidate.TitleTag = proposal.ArtistTag, proposal.TitleTag
+		candidate.ArtistKey, candidate.TitleKey = proposal.ArtistKey, proposal.TitleKey
+		candidate.ArtistXPath, candidate.TitleXPath = proposal.ArtistXPath, proposal.TitleXPath
+		candidate.Regex = proposal.Regex
+
+		key, _ := json.Marshal(candidate)
+		if seen[string(key)] {
+			continue
+		}
+		seen[string(key)] = true
+
+		songs, err := scrapeBody(candidate, body)
+		if err != nil || !containsSong(songs, artist, title) {
+			utils.Logger.Debugf("Discarding %s candidate: %v", candidate.Type, err)
+			continue
+		}
+		candidates = append(candidates, Candidate{Station: candidate, Songs: songs})
+	}
+
+	return candidates, nil
+}
+
+// scrapeBody parses an already fetched response with the scraper of the station
+func scrapeBody(station utils.Station, body []byte) ([]*Song, error) {
+	scraperInstance, err := New(station)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bytes.NewReader(body)
+	switch s := scraperInstance.(type) {
+	case *HTMLScraper:
+		return singleSong(s.parse(reader))
+	case *JSONScraper:
+		return s.parse(reader)
+	case *XMLScraper:
+		return s.parse(reader)
+	case *PlaintextScraper:
+		return singleSong(s.parse(reader))
+	}
+	return nil, fmt.Errorf("%s stations can't be checked against a response", station.Type)
+}
+
+func containsSong(songs []*Song, artist, title string) bool {
+	for _, song := range songs {
+		if sameText(song.Artist, artist) && sameText(song.Title, title) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameText(value, want string) bool {
+	return strings.EqualFold(strings.TrimSpace(value), want)
+}
+
+// discoverJSON proposes key paths for every pair of values matching the artist and title. If both paths run
+// through the same array, a variant with a wildcard for that array is proposed first to scrape the whole history.
+func discoverJSON(body []byte, artist, title string) []utils.Station {
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil
+	}
+
+	artistPaths := findJSONPaths(data, nil, artist)
+	titlePaths := findJSONPaths(data, nil, title)
+
+	var proposals []utils.Station
+	for _, artistPath := range artistPaths {
+		for _, titlePath := range titlePaths {
+			if artistWildcard, titleWildcard, ok := wildcardPaths(artistPath, titlePath); ok {
+				proposals = append(proposals, utils.Station{Type: "json", ArtistKey: artistWildcard, TitleKey: titleWildcard})
+			}
+			proposals = append(proposals, utils.Station{Type: "json", ArtistKey: artistPath, TitleKey: titlePath})
+		}
+	}
+	return proposals
+}
+
+// findJSONPaths returns the key paths of all string values matching want
+func findJSONPaths(value interface{}, path []interface{}, want string) [][]interface{} {
+	switch value := value.(type) {
+	case string:
+		if sameText(value, want) {
+			return [][]interface{}{append([]interface{}{}, path...)}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var paths [][]interface{}
+		for _, key := range keys {
+			paths = append(paths, findJSONPaths(value[key], append(path, key), want)...)
+		}
+		return paths
+	case []interface{}:
+		var paths [][]interface{}
+		for i, item := range value {
+			paths = append(paths, findJSONPaths(item, append(path, i), want)...)
+		}
+		return paths
+	}
+	return nil
+}
+
+// wildcardPaths replaces the innermost array index shared by both paths with Wildcard
+func wildcardPaths(artistPath, titlePath []interface{}) ([]interface{}, []interface{}, bool) {
+	index := -1
+	for i := 0; i < len(artistPath) && i < len(titlePath) && artistPath[i] == titlePath[i]; i++ {
+		if _, ok := artistPath[i].(int); ok {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, nil, false
+	}
+
+	artistWildcard := append([]interface{}{}, artistPath...)
+	titleWildcard := append([]interface{}{}, titlePath...)
+	artistWildcard[index] = Wildcard
+	titleWildcard[index] = Wildcard
+	return artistWildcard, titleWildcard, true
+}
+
+// discoverXML proposes XPaths for every pair of elements or attributes matching the artist and title. Like for
+// JSON, a variant matching all siblings of the innermost shared repeated element is proposed first.
+func discoverXML(body []byte, artist, title string) []utils.Station {
+	doc, err := xmlquery.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	artistPaths := findXMLPaths(doc, nil, artist)
+	titlePaths := findXMLPaths(doc, nil, title)
+
+	var proposals []utils.Station
+	for _, artistPath := range artistPaths {
+		for _, titlePath := range titlePaths {
+			if artistAll, titleAll, ok := repeatedXPaths(artistPath, titlePath); ok {
+				proposals = append(proposals, utils.Station{Type: "xml", ArtistXPath: artistAll, TitleXPath: titleAll})
+			}
+			proposals = append(proposals, utils.Station{Type: "xml", ArtistXPath: xpathString(artistPath), TitleXPath: xpathString(titlePath)})
+		}
+	}
+	return proposals
+}
+
+// xpathStep is one location step of a discovered XPath, with its position among siblings of the same name
+type xpathStep struct {
+	name     string
+	position int // 0 if the element has no siblings of the same name
+}
+
+// findXMLPaths returns the paths of all elements and attributes whose text matches want. Elements only match if
+// none of their children does, so the innermost element is used.
+func findXMLPaths(node *xmlquery.Node, path []xpathStep, want string) [][]xpathStep {
+	var paths [][]xpathStep
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != xmlquery.ElementNode {
+			continue
+		}
+		childPath := append(append([]xpathStep{}, path...), xpathStep{name: xmlName(child), position: xmlPosition(child)})
+
+		for _, attr := range child.Attr {
+			if sameText(attr.Value, want) {
+				attrPath := append(append([]xpathStep{}, childPath...), xpathStep{name: "@" + attr.Name.Local})
+				paths = append(paths, attrPath)
+			}
+		}
+
+		childPaths := findXMLPaths(child, childPath, want)
+		if len(childPaths) == 0 && sameText(child.InnerText(), want) {
+			childPaths = [][]xpathStep{childPath}
+		}
+		paths = append(paths, childPaths...)
+	}
+	return paths
+}
+
+func xmlName(node *xmlquery.Node) string {
+	if node.Prefix != "" {
+		return node.Prefix + ":" + node.Data
+	}
+	return node.Data
+}
+
+// xmlPosition returns the 1-based position of an element among its siblings of the same name, or 0 if it has none
+func xmlPosition(node *xmlquery.Node) int {
+	position, count := 0, 0
+	for sibling := node.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type != xmlquery.ElementNode || xmlName(sibling) != xmlName(node) {
+			continue
+		}
+		count++
+		if sibling == node {
+			position = count
+		}
+	}
+	if count == 1 {
+		return 0
+	}
+	return position
+}
+
+func xpathString(path []xpathStep) string {
+	var builder strings.Builder
+	for _, step := range path {
+		builder.WriteString("/" + step.name)
+		if step.position > 0 {
+			builder.WriteString("[" + strconv.Itoa(step.position) + "]")
+		}
+	}
+	return builder.String()
+}
+
+// repeatedXPaths drops the position of the innermost repeated element shared by both paths
+func repeatedXPaths(artistPath, titlePath []xpathStep) (string, string, bool) {
+	index := -1
+	for i := 0; i < len(artistPath) && i < len(titlePath) && artistPath[i] == titlePath[i]; i++ {
+		if artistPath[i].position > 0 {
+			index = i
+		}
+	}
+	if index < 0 {
+		return "", "", false
+	}
+
+	artistAll := append([]xpathStep{}, artistPath...)
+	titleAll := append([]xpathStep{}, titlePath...)
+	artistAll[index].position = 0
+	titleAll[index].position = 0
+	return xpathString(artistAll), xpathString(titleAll), true
+}
+
+// discoverHTML proposes CSS selectors for every pair of elements matching the artist and title. Each pair gets
+// selectors based on IDs and classes and structural ones based on the position in the document.
+func discoverHTML(body []byte, artist, title string) []utils.Station {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var proposals []utils.Station
+	for _, artistElement := range findHTMLElements(doc, artist) {
+		for _, titleElement := range findHTMLElements(doc, title) {
+			proposals = append(proposals,
+				utils.Station{Type: "html", ArtistTag: classSelector(artistElement), TitleTag: classSelector(titleElement)},
+				utils.Station{Type: "html", ArtistTag: structuralSelector(artistElement), TitleTag: structuralSelector(titleElement)},
+			)
+		}
+	}
+	return proposals
+}
+
+// findHTMLElements returns the innermost elements whose text matches want
+func findHTMLElements(doc *goquery.Document, want string) []*goquery.Selection {
+	var elements []*goquery.Selection
+	doc.Find("body *").Each(func(_ int, sel *goquery.Selection) {
+		if !sameText(sel.Text(), want) {
+			return
+		}
+		innermost := true
+		sel.Children().EachWithBreak(func(_ int, child *goquery.Selection) bool {
+			innermost = !sameText(child.Text(), want)
+			return innermost
+		})
+		if innermost {
+			elements = append(elements, sel)
+		}
+	})
+	return elements
+}
+
+// classSelector builds a selector from the element's tag and classes, prefixed by its ancestors up to the closest
+// one with an ID
+func classSelector(sel *goquery.Selection) string {
+	var steps []string
+	for ; sel.Length() > 0 && !sel.Is("body"); sel = sel.Parent() {
+		if id, ok := sel.Attr("id"); ok && cssIdentRegex.MatchString(id) {
+			steps = append(steps, "#"+id)
+			break
+		}
+
+		step := goquery.NodeName(sel)
+		for _, class := range strings.Fields(sel.AttrOr("class", "")) {
+			if cssIdentRegex.MatchString(class) {
+				step += "." + class
+			}
+		}
+		steps = append(steps, step)
+	}
+
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return strings.Join(steps, " ")
+}
+
+// structuralSelector builds a selector from the element's position below body
+func structuralSelector(sel *goquery.Selection) string {
+	var steps []string
+	for ; sel.Length() > 0 && !sel.Is("body"); sel = sel.Parent() {
+		steps = append(steps, fmt.Sprintf("%s:nth-child(%d)", goquery.NodeName(sel), sel.Index()+1))
+	}
+	steps = append(steps, "body")
+
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return strings.Join(steps, " > ")
+}
+
+// discoverRegex proposes a plaintext regex for each line containing both the artist and the title, keeping a bit of
+// the surrounding text as context. Numbers in the context are generalised, as they are usually times or IDs.
+func discoverRegex(body []byte, artist, title string) []utils.Station {
+	artistRegex := regexp.MustCompile("(?i)" + regexp.QuoteMeta(artist))
+	titleRegex := regexp.MustCompile("(?i)" + regexp.QuoteMeta(title))
+
+	var proposals []utils.Station
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimRight(line, "\r")
+		artistIndex := artistRegex.FindStringIndex(line)
+		titleIndex := titleRegex.FindStringIndex(line)
+		if artistIndex == nil || titleIndex == nil {
+			continue
+		}
+
+		first, second := artistIndex, titleIndex
+		firstGroup, secondGroup := "(?P<artist>.+?)", "(?P<title>.+?)"
+		if titleIndex[0] < artistIndex[0] {
+			first, second = titleIndex, artistIndex
+			firstGroup, secondGroup = secondGroup, firstGroup
+		}
+		if first[1] >= second[0] {
+			continue // Overlapping or adjacent, nothing to split on
+		}
+
+		before, after := line[:first[0]], line[second[1]:]
+		var regex strings.Builder
+		if len(before) <= discoverRegexContext {
+			regex.WriteString("^")
+		} else {
+			before = before[runeStart(before, len(before)-discoverRegexContext):]
+		}
+		regex.WriteString(regexLiteral(before))
+		regex.WriteString(firstGroup)
+		regex.WriteString(regexLiteral(line[first[1]:second[0]]))
+		regex.WriteString(secondGroup)
+		if len(after) <= discoverRegexContext {
+			regex.WriteString(regexLiteral(after) + "$")
+		} else {
+			regex.WriteString(regexLiteral(after[:runeStart(after, discoverRegexContext)]))
+		}
+
+		proposals = append(proposals, utils.Station{Type: "plaintext", Regex: regex.String()})
+	}
+	return proposals
+}
+
+func regexLiteral(s string) string {
+	return digitsRegex.ReplaceAllString(regexp.QuoteMeta(s), `\d+`)
+}
+
+// runeStart moves index back to the start of a UTF-8 character
+func runeStart(s string, index int) int {
+	for index > 0 && !utf8.RuneStart(s[index]) {
+		index--
+	}
+	return index
+}
@@ -0,0 +1,148 @@
+package scraper
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"radio-to-spotify/utils"
+)
+
+// selectors returns the part of a station definition that Discover fills in
+func selectors(station utils.Station) utils.Station {
+	return utils.Station{
+		Type:        station.Type,
+		ArtistTag:   station.ArtistTag,
+		TitleTag:    station.TitleTag,
+		ArtistKey:   station.ArtistKey,
+		TitleKey:    station.TitleKey,
+		ArtistXPath: station.ArtistXPath,
+		TitleXPath:  station.TitleXPath,
+		Regex:       station.Regex,
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantFirst   utils.Station // Best candidate
+		wantSongs   int           // Songs scraped by the best candidate
+		wantAlso    []utils.Station
+	}{
+		{
+			name:        "html",
+			contentType: "text/html",
+			body: `<html><body>
+				<h1>Test FM</h1>
+				<div id="now"><span class="artist">Queen</span> <span class="title">Under Pressure</span></div>
+			</body></html>`,
+			wantFirst: utils.Station{Type: "html", ArtistTag: "#now span.artist", TitleTag: "#now span.title"},
+			wantSongs: 1,
+			wantAlso: []utils.Station{
+				{Type: "html", ArtistTag: "body > div:nth-child(2) > span:nth-child(1)", TitleTag: "body > div:nth-child(2) > span:nth-child(2)"},
+			},
+		},
+		{
+			name:        "json",
+			contentType: "application/json",
+			body: `{
+				"history": [{"artist": "Daft Punk", "title": "One More Time"}, {"artist": "queen", "title": "under pressure"}],
+				"now": {"artist": "Queen", "title": "Under Pressure"}
+			}`,
+			// The shared array becomes a wildcard to scrape the whole history
+			wantFirst: utils.Station{Type: "json", ArtistKey: []interface{}{"history", Wildcard, "artist"}, TitleKey: []interface{}{"history", Wildcard, "title"}},
+			wantSongs: 2,
+			wantAlso: []utils.Station{
+				{Type: "json", ArtistKey: []interface{}{"history", 1, "artist"}, TitleKey: []interface{}{"history", 1, "title"}},
+				{Type: "json", ArtistKey: []interface{}{"now", "artist"}, TitleKey: []interface{}{"now", "title"}},
+			},
+		},
+		{
+			name:        "xml",
+			contentType: "application/xml",
+			body: `<?xml version="1.0"?>
+				<playlist>
+					<track><artist>Daft Punk</artist><title>One More Time</title></track>
+					<track><artist>Queen</artist><title>Under Pressure</title></track>
+					<current artist="Queen" title="Under Pressure"/>
+				</playlist>`,
+			// The repeated element loses its position to scrape the whole history
+			wantFirst: utils.Station{Type: "xml", ArtistXPath: "/playlist/track/artist", TitleXPath: "/playlist/track/title"},
+			wantSongs: 2,
+			wantAlso: []utils.Station{
+				{Type: "xml", ArtistXPath: "/playlist/track[2]/artist", TitleXPath: "/playlist/track[2]/title"},
+				{Type: "xml", ArtistXPath: "/playlist/current/@artist", TitleXPath: "/playlist/current/@title"},
+			},
+		},
+		{
+			name:        "plaintext",
+			contentType: "text/plain",
+			body:        "Now playing: Queen - Under Pressure (4:08)\n",
+			wantFirst:   utils.Station{Type: "plaintext", Regex: `^Now playing: (?P<artist>.+?) - (?P<title>.+?) \(\d+:\d+\)$`},
+			wantSongs:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFeedServer(t, tt.contentType, tt.body)
+			station := utils.Station{ID: "discover_" + tt.name, Name: "Test FM", URL: server.URL}
+
+			candidates, err := Discover(context.Background(), station, " Queen ", "Under Pressure")
+			if err != nil {
+				t.Fatalf("Discover: %v", err)
+			}
+			if len(candidates) == 0 {
+				t.Fatal("Discover found no candidates")
+			}
+
+			var found []utils.Station
+			for _, candidate := range candidates {
+				// Candidates are based on the given station and find the song
+				if candidate.Station.ID != station.ID || candidate.Station.Name != station.Name || candidate.Station.URL != station.URL {
+					t.Errorf("candidate %+v doesn't keep the station", candidate.Station)
+				}
+				if !containsSong(candidate.Songs, "Queen", "Under Pressure") {
+					t.Errorf("candidate %+v doesn't find the song: %v", selectors(candidate.Station), candidate.Songs)
+				}
+				found = append(found, selectors(candidate.Station))
+			}
+
+			if !reflect.DeepEqual(found[0], tt.wantFirst) {
+				t.Errorf("first candidate = %+v, want %+v", found[0], tt.wantFirst)
+			}
+			if len(candidates[0].Songs) != tt.wantSongs {
+				t.Errorf("first candidate scraped %d songs, want %d", len(candidates[0].Songs), tt.wantSongs)
+			}
+			for _, want := range tt.wantAlso {
+				if !containsStation(found, want) {
+					t.Errorf("candidates %+v don't include %+v", found, want)
+				}
+			}
+		})
+	}
+}
+
+func containsStation(stations []utils.Station, want utils.Station) bool {
+	for _, station := range stations {
+		if reflect.DeepEqual(station, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDiscoverNotFound(t *testing.T) {
+	server := newFeedServer(t, "application/json", `{"now": {"artist": "Daft Punk", "title": "One More Time"}}`)
+	candidates, err := Discover(context.Background(), utils.Station{ID: "discover_not_found", URL: server.URL}, "Queen", "Under Pressure")
+	if err != nil || len(candidates) != 0 {
+		t.Errorf("Discover = %+v, %v, want no candidates", candidates, err)
+	}
+
+	if _, err := Discover(context.Background(), utils.Station{ID: "discover_not_found", URL: server.URL}, "Queen", " "); err == nil || !strings.Contains(err.Error(), "required") {
+		t.Errorf("Discover without a title error = %v, want required", err)
+	}
+}
@@ -1,51 +1,94 @@
 package scraper
 
 import (
+	"context"
 	"fmt"
-	"net/http"
+	"io"
+	"radio-to-spotify/utils"
+	"strings"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
 )
 
-type HTMLScraper struct {
-	*BaseScraper
-	ArtistTag string
-	TitleTag  string
+func init() {
+	Register("html", func(station utils.Station) (Scraper, error) {
+		return NewHTMLScraper(station)
+	})
 }
 
-func NewHTMLScraper(URL string, artistTag string, titleTag string) *HTMLScraper {
-	return &HTMLScraper{
-		BaseScraper: NewBaseScraper(URL),
-		ArtistTag:   artistTag,
-		TitleTag:    titleTag,
-	}
+type HTMLScraper struct {
+	*BaseScraper
+	ArtistTag    string
+	TitleTag     string
+	MetadataTags map[string]string // Optional metadata field name -> CSS selector
 }
 
-func (s *HTMLScraper) GetNowPlaying() (*Song, error) {
-	resp, err := http.Get(s.URL)
+func NewHTMLScraper(station utils.Station) (*HTMLScraper, error) {
+	err := requireFields(
+		requiredField{"artistTag", station.ArtistTag != ""},
+		requiredField{"titleTag", station.TitleTag != ""},
+	)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("error fetching URL: %s, status code: %d", s.URL, resp.StatusCode)
+	metadataTags := metadataSelectors(station.PlayedAtTag, station.DurationTag, station.AlbumTag, station.ISRCTag, station.ExtraTags, isSetString)
+	tags := map[string]string{"artist": station.ArtistTag, "title": station.TitleTag}
+	for field, tag := range metadataTags {
+		tags[field] = tag
+	}
+	// goquery silently matches nothing for invalid selectors, so check them upfront
+	for field, tag := range tags {
+		if _, err := cascadia.Compile(tag); err != nil {
+			return nil, fmt.Errorf("invalid %s selector %q: %v", field, tag, err)
+		}
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	return &HTMLScraper{
+		BaseScraper:  NewBaseScraper(station),
+		ArtistTag:    station.ArtistTag,
+		TitleTag:     station.TitleTag,
+		MetadataTags: metadataTags,
+	}, nil
+}
+
+func (s *HTMLScraper) GetHistory(ctx context.Context) ([]*Song, error) {
+	return s.fetch(ctx, func(body io.Reader) ([]*Song, error) {
+		return singleSong(s.parse(body))
+	})
+}
+
+func (s *HTMLScraper) GetNowPlaying(ctx context.Context) (*Song, error) {
+	return firstSong(s.GetHistory(ctx))
+}
+
+func (s *HTMLScraper) parse(body io.Reader) (*Song, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
 	if err != nil {
 		return nil, err
 	}
 
 	artist := doc.Find(s.ArtistTag).Text()
 	title := doc.Find(s.TitleTag).Text()
+	s.traceMatch("artist", s.ArtistTag, artist)
+	s.traceMatch("title", s.TitleTag, title)
 
 	if artist == "" || title == "" {
 		return nil, fmt.Errorf("could not find artist or title in HTML")
 	}
 
-	return &Song{
+	song := &Song{
 		Artist: artist,
 		Title:  title,
-	}, nil
+	}
+	for field, tag := range s.MetadataTags {
+		value := strings.TrimSpace(doc.Find(tag).First().Text())
+		s.traceMatch(field, tag, value)
+		if value != "" {
+			setMetadata(song, field, value)
+		}
+	}
+
+	return song, nil
 }
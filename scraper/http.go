@@ -0,0 +1,185 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"radio-to-spotify/utils"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTimeout   = 15 * time.Second
+	DefaultUserAgent = "Mozilla/5.0 (compatible; radio-to-spotify; +https://github.com/Ceddicedced/radio-to-spotify)"
+)
+
+// Shared client so all scrapers reuse connections. Timeouts are set per request.
+var httpClient = &http.Client{}
+
+// Validators and parsed songs of the last response per station, used for conditional requests
+var conditionalCache = struct {
+	mu      sync.Mutex
+	entries map[string]conditionalEntry
+}{entries: make(map[string]conditionalEntry)}
+
+type conditionalEntry struct {
+	ETag         string
+	LastModified string
+	Songs        []*Song
+}
+
+type BaseScraper struct {
+	StationID string
+	URL       string
+	Timeout   time.Duration
+	UserAgent string
+	Headers   map[string]string
+	client    *http.Client
+	trace     *Trace
+}
+
+func NewBaseScraper(station utils.Station) *BaseScraper {
+	timeout := time.Duration(station.Timeout)
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	userAgent := station.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	return &BaseScraper{
+		StationID: station.ID,
+		URL:       station.URL,
+		Timeout:   timeout,
+		UserAgent: userAgent,
+		Headers:   station.Headers,
+		client:    httpClient,
+	}
+}
+
+// newRequest creates a GET request for the station URL with the configured User-Agent and headers
+func (s *BaseScraper) newRequest(ctx context.Context) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", s.UserAgent)
+	req.Header.Set("Accept-Encoding", "gzip")
+	for key, value := range s.Headers {
+		req.Header.Set(key, value)
+	}
+	return req, nil
+}
+
+// fetch requests the station URL and passes the body to parse. If the feed hasn't changed since the last
+// request (304 Not Modified), the songs parsed from the previous response are returned without parsing again.
+// Traced scrapes always request the full response.
+func (s *BaseScraper) fetch(ctx context.Context, parse func(body io.Reader) ([]*Song, error)) ([]*Song, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	req, err := s.newRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := s.StationID + "|" + s.URL
+	var cached conditionalEntry
+	var hasCached bool
+	if s.trace == nil {
+		conditionalCache.mu.Lock()
+		cached, hasCached = conditionalCache.entries[cacheKey]
+		conditionalCache.mu.Unlock()
+	}
+	if hasCached {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if s.trace != nil {
+		s.trace.StatusCode = resp.StatusCode
+	}
+
+	if resp.StatusCode == http.StatusNotModified && hasCached {
+		utils.Logger.Debugf("Feed not modified: %s", s.URL)
+		return copySongs(cached.Songs), nil
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("error fetching URL: %s, status code: %d", s.URL, resp.StatusCode)
+	}
+
+	body, err := decodeBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var reader io.Reader = body
+	if s.trace != nil {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		s.trace.Body = data
+		reader = bytes.NewReader(data)
+	}
+
+	songs, err := parse(reader)
+	if err != nil {
+		return nil, err
+	}
+	if s.trace != nil {
+		return songs, nil
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	conditionalCache.mu.Lock()
+	if etag != "" || lastModified != "" {
+		conditionalCache.entries[cacheKey] = conditionalEntry{
+			ETag:         etag,
+			LastModified: lastModified,
+			Songs:        copySongs(songs),
+		}
+	} else {
+		delete(conditionalCache.entries, cacheKey)
+	}
+	conditionalCache.mu.Unlock()
+
+	return songs, nil
+}
+
+// decodeBody returns the response body, decompressing it if the server sent it gzipped
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		return io.NopCloser(resp.Body), nil
+	}
+	return gzip.NewReader(resp.Body)
+}
+
+// copySongs returns shallow copies of the songs so cached results can't be modified by callers
+func copySongs(songs []*Song) []*Song {
+	copies := make([]*Song, len(songs))
+	for i, song := range songs {
+		songCopy := *song
+		copies[i] = &songCopy
+	}
+	return copies
+}
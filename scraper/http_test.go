@@ -0,0 +1,133 @@
+package scraper
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"radio-to-spotify/utils"
+)
+
+// parseLine returns the body as the title of a single song and counts the calls
+func parseLine(calls *int) func(io.Reader) ([]*Song, error) {
+	return func(body io.Reader) ([]*Song, error) {
+		*calls++
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		return []*Song{{Artist: "Artist", Title: string(data)}}, nil
+	}
+}
+
+func TestFetchNotModified(t *testing.T) {
+	var requests []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Clone())
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Write([]byte("Title"))
+	}))
+	defer server.Close()
+
+	s := NewBaseScraper(utils.Station{ID: "test", URL: server.URL})
+	calls := 0
+
+	songs, err := s.fetch(context.Background(), parseLine(&calls))
+	if err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+	// Changes by the caller must not end up in the cached songs
+	songs[0].Title = "Changed"
+
+	songs, err = s.fetch(context.Background(), parseLine(&calls))
+	if err != nil {
+		t.Fatalf("second fetch: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("body parsed %d times, want 1", calls)
+	}
+	if len(songs) != 1 || songs[0].Title != "Title" {
+		t.Errorf("second fetch = %v, want the songs of the first response", songs)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("server got %d requests, want 2", len(requests))
+	}
+	if requests[0].Get("If-None-Match") != "" {
+		t.Errorf("first request sent If-None-Match %q", requests[0].Get("If-None-Match"))
+	}
+	if got := requests[1].Get("If-Modified-Since"); got != "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Errorf("second request sent If-Modified-Since %q", got)
+	}
+}
+
+func TestFetchWithoutValidators(t *testing.T) {
+	conditional := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			conditional++
+		}
+		w.Write([]byte("Title"))
+	}))
+	defer server.Close()
+
+	s := NewBaseScraper(utils.Station{ID: "test", URL: server.URL})
+	calls := 0
+	for i := 0; i < 2; i++ {
+		if _, err := s.fetch(context.Background(), parseLine(&calls)); err != nil {
+			t.Fatalf("fetch %d: %v", i+1, err)
+		}
+	}
+	if calls != 2 || conditional != 0 {
+		t.Errorf("parsed %d times with %d conditional requests, want 2 and 0", calls, conditional)
+	}
+}
+
+func TestFetchNotModifiedWithoutCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	// A 304 without a cached response can't be served and is an error
+	s := NewBaseScraper(utils.Station{ID: "test", URL: server.URL})
+	calls := 0
+	if _, err := s.fetch(context.Background(), parseLine(&calls)); err == nil {
+		t.Fatal("fetch succeeded on a 304 without a cached response")
+	}
+}
+
+func TestFetchTracedSkipsCache(t *testing.T) {
+	conditional := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			conditional++
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("Title"))
+	}))
+	defer server.Close()
+
+	station := utils.Station{ID: "test", URL: server.URL}
+	calls := 0
+	if _, err := NewBaseScraper(station).fetch(context.Background(), parseLine(&calls)); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+
+	traced := NewBaseScraper(station)
+	trace := &Trace{}
+	traced.setTrace(trace)
+	if _, err := traced.fetch(context.Background(), parseLine(&calls)); err != nil {
+		t.Fatalf("traced fetch: %v", err)
+	}
+	if conditional != 0 || string(trace.Body) != "Title" || trace.StatusCode != http.StatusOK {
+		t.Errorf("traced fetch sent %d conditional requests and recorded %d %q", conditional, trace.StatusCode, trace.Body)
+	}
+}
@@ -0,0 +1,178 @@
+package scraper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"radio-to-spotify/utils"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Maximum number of audio blocks to read while waiting for a non-empty metadata block
+const icyMaxBlocks = 4
+
+var streamTitleRegex = regexp.MustCompile(`(?s)StreamTitle='(.*?)';`)
+
+func init() {
+	Register("icy", func(station utils.Station) (Scraper, error) {
+		return NewICYScraper(station)
+	})
+}
+
+type ICYScraper struct {
+	*BaseScraper
+	Separator string
+	Regex     *regexp.Regexp
+}
+
+func NewICYScraper(station utils.Station) (*ICYScraper, error) {
+	var compiledRegex *regexp.Regexp
+	if station.Regex != "" {
+		var err error
+		compiledRegex, err = compileSongRegex(station.Regex)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	separator := station.Separator
+	if separator == "" {
+		separator = " - "
+	}
+
+	return &ICYScraper{
+		BaseScraper: NewBaseScraper(station),
+		Separator:   separator,
+		Regex:       compiledRegex,
+	}, nil
+}
+
+func (s *ICYScraper) GetHistory(ctx context.Context) ([]*Song, error) {
+	return singleSong(s.GetNowPlaying(ctx))
+}
+
+func (s *ICYScraper) GetNowPlaying(ctx context.Context) (*Song, error) {
+	// The stream never ends, so the timeout also bounds reading the body. Conditional requests don't apply to streams.
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	req, err := s.newRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Icy-MetaData", "1")
+	// Compression makes no sense for audio and would break the metadata offsets
+	req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("error fetching URL: %s, status code: %d", s.URL, resp.StatusCode)
+	}
+
+	metaint, err := strconv.Atoi(resp.Header.Get("icy-metaint"))
+	if err != nil || metaint <= 0 {
+		return nil, fmt.Errorf("stream does not provide a valid icy-metaint header")
+	}
+
+	if s.trace != nil {
+		s.trace.StatusCode = resp.StatusCode
+	}
+
+	metadata, err := readICYMetadata(ctx, bufio.NewReader(resp.Body), metaint)
+	if err != nil {
+		return nil, err
+	}
+	if s.trace != nil {
+		s.trace.Body = []byte(metadata)
+	}
+
+	streamTitle, err := parseStreamTitle(metadata)
+	if err != nil {
+		return nil, err
+	}
+	s.traceMatch("streamTitle", streamTitleRegex.String(), streamTitle)
+
+	return s.splitStreamTitle(streamTitle)
+}
+
+// readICYMetadata skips the audio bytes of each block and returns the first non-empty metadata block. Reading stops
+// between blocks once the context is done.
+func readICYMetadata(ctx context.Context, r io.Reader, metaint int) (string, error) {
+	lengthByte := make([]byte, 1)
+	for i := 0; i < icyMaxBlocks; i++ {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(metaint)); err != nil {
+			return "", fmt.Errorf("error skipping audio data: %v", err)
+		}
+
+		if _, err := io.ReadFull(r, lengthByte); err != nil {
+			return "", fmt.Errorf("error reading metadata length: %v", err)
+		}
+
+		// The length byte counts 16 byte chunks
+		length := int(lengthByte[0]) * 16
+		if length == 0 {
+			continue
+		}
+
+		metadata := make([]byte, length)
+		if _, err := io.ReadFull(r, metadata); err != nil {
+			return "", fmt.Errorf("error reading metadata: %v", err)
+		}
+
+		// Metadata blocks are padded with NULL bytes
+		return strings.TrimRight(string(metadata), "\x00"), nil
+	}
+
+	return "", fmt.Errorf("no metadata found in the first %d blocks", icyMaxBlocks)
+}
+
+func parseStreamTitle(metadata string) (string, error) {
+	matches := streamTitleRegex.FindStringSubmatch(metadata)
+	if len(matches) != 2 || strings.TrimSpace(matches[1]) == "" {
+		return "", fmt.Errorf("no StreamTitle found in metadata")
+	}
+	return strings.TrimSpace(matches[1]), nil
+}
+
+// splitStreamTitle splits "Artist - Title" using the configured regex or separator
+func (s *ICYScraper) splitStreamTitle(streamTitle string) (*Song, error) {
+	var song *Song
+
+	if s.Regex != nil {
+		matches := s.Regex.FindStringSubmatch(streamTitle)
+		if matches == nil {
+			return nil, fmt.Errorf("regex does not match stream title: %s", streamTitle)
+		}
+		s.traceMatch("regex", s.Regex.String(), matches...)
+		song = songFromMatch(s.Regex, matches)
+	} else {
+		parts := strings.SplitN(streamTitle, s.Separator, 2)
+		s.traceMatch("separator", s.Separator, parts...)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("separator %q not found in stream title: %s", s.Separator, streamTitle)
+		}
+		song = &Song{
+			Artist: parts[0],
+			Title:  parts[1],
+		}
+	}
+
+	song.Artist = strings.TrimSpace(song.Artist)
+	song.Title = strings.TrimSpace(song.Title)
+	if song.Artist == "" || song.Title == "" {
+		return nil, fmt.Errorf("could not find artist or title in stream title: %s", streamTitle)
+	}
+
+	return song, nil
+}
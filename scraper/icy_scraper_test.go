@@ -0,0 +1,153 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"radio-to-spotify/utils"
+)
+
+const testMetaint = 16
+
+// icyStream returns the body of a stream with a metadata block after every testMetaint bytes of audio. Metadata is
+// padded to 16 byte chunks, an empty string is an empty block.
+func icyStream(metadata ...string) []byte {
+	var stream bytes.Buffer
+	for _, block := range metadata {
+		stream.Write(bytes.Repeat([]byte{0xff}, testMetaint))
+		chunks := (len(block) + 15) / 16
+		stream.WriteByte(byte(chunks))
+		stream.WriteString(block)
+		stream.Write(make([]byte, chunks*16-len(block)))
+	}
+	return stream.Bytes()
+}
+
+func newICYServer(t *testing.T, body []byte) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Icy-MetaData") != "1" {
+			t.Errorf("Icy-MetaData header = %q, want 1", r.Header.Get("Icy-MetaData"))
+		}
+		w.Header().Set("icy-metaint", strconv.Itoa(testMetaint))
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestICYScraper(t *testing.T) {
+	tests := []struct {
+		name       string
+		station    utils.Station
+		metadata   []string
+		wantArtist string
+		wantTitle  string
+		wantErr    string
+	}{
+		{
+			name:       "separator",
+			metadata:   []string{"StreamTitle='Queen - Don't Stop Me Now';"},
+			wantArtist: "Queen",
+			wantTitle:  "Don't Stop Me Now",
+		},
+		{
+			name:       "custom separator",
+			station:    utils.Station{Separator: " | "},
+			metadata:   []string{"StreamTitle='Queen | Under Pressure - Remastered';"},
+			wantArtist: "Queen",
+			wantTitle:  "Under Pressure - Remastered",
+		},
+		{
+			name:       "empty first block",
+			metadata:   []string{"", "", "StreamTitle='Daft Punk - One More Time';StreamUrl='';"},
+			wantArtist: "Daft Punk",
+			wantTitle:  "One More Time",
+		},
+		{
+			name:       "regex",
+			station:    utils.Station{Regex: `^(?P<title>.+) by (?P<artist>.+)$`},
+			metadata:   []string{"StreamTitle='Around the World by Daft Punk';"},
+			wantArtist: "Daft Punk",
+			wantTitle:  "Around the World",
+		},
+		{
+			name:     "regex without match",
+			station:  utils.Station{Regex: `^(?P<title>.+) by (?P<artist>.+)$`},
+			metadata: []string{"StreamTitle='Station Jingle';"},
+			wantErr:  "regex does not match",
+		},
+		{
+			name:     "missing separator",
+			metadata: []string{"StreamTitle='Station Jingle';"},
+			wantErr:  "separator",
+		},
+		{
+			name:     "missing StreamTitle",
+			metadata: []string{"StreamUrl='https://example.com';"},
+			wantErr:  "no StreamTitle",
+		},
+		{
+			name:     "only empty blocks",
+			metadata: []string{"", "", "", "", ""},
+			wantErr:  "no metadata found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newICYServer(t, icyStream(tt.metadata...))
+			station := tt.station
+			station.ID = "test"
+			station.Type = "icy"
+			station.URL = server.URL
+
+			icy, err := NewICYScraper(station)
+			if err != nil {
+				t.Fatalf("NewICYScraper: %v", err)
+			}
+			song, err := icy.GetNowPlaying(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetNowPlaying error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetNowPlaying: %v", err)
+			}
+			if song.Artist != tt.wantArtist || song.Title != tt.wantTitle {
+				t.Errorf("GetNowPlaying = %q - %q, want %q - %q", song.Artist, song.Title, tt.wantArtist, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestICYScraperInvalidMetaint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("no metadata"))
+	}))
+	defer server.Close()
+
+	icy, err := NewICYScraper(utils.Station{ID: "test", Type: "icy", URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewICYScraper: %v", err)
+	}
+	if _, err := icy.GetNowPlaying(context.Background()); err == nil || !strings.Contains(err.Error(), "icy-metaint") {
+		t.Fatalf("GetNowPlaying error = %v, want icy-metaint error", err)
+	}
+}
+
+func TestReadICYMetadataCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := readICYMetadata(ctx, bytes.NewReader(icyStream("StreamTitle='A - B';")), testMetaint); err != context.Canceled {
+		t.Fatalf("readICYMetadata error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -1,94 +1,218 @@
 package scraper
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"io"
+	"radio-to-spotify/utils"
 )
 
-type JSONScraper struct {
-	*BaseScraper
-	ArtistKey []interface{}
-	TitleKey  []interface{}
+// Wildcard matches every element of an array in a key path, e.g. ["songs", "*", "artist"]
+const Wildcard = "*"
+
+func init() {
+	Register("json", func(station utils.Station) (Scraper, error) {
+		return NewJSONScraper(station)
+	})
 }
 
-func NewJSONScraper(URL string, artistKey []interface{}, titleKey []interface{}) *JSONScraper {
-	return &JSONScraper{
-		BaseScraper: NewBaseScraper(URL),
-		ArtistKey:   artistKey,
-		TitleKey:    titleKey,
-	}
+type JSONScraper struct {
+	*BaseScraper
+	ArtistKey    []interface{}
+	TitleKey     []interface{}
+	MetadataKeys map[string][]interface{} // Optional metadata field name -> key path
 }
 
-func (s *JSONScraper) GetNowPlaying() (*Song, error) {
-	resp, err := http.Get(s.URL)
+func NewJSONScraper(station utils.Station) (*JSONScraper, error) {
+	err := requireFields(
+		requiredField{"artistKey", len(station.ArtistKey) > 0},
+		requiredField{"titleKey", len(station.TitleKey) > 0},
+	)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("error fetching URL: %s, status code: %d", s.URL, resp.StatusCode)
+	metadataKeys := metadataSelectors(station.PlayedAtKey, station.DurationKey, station.AlbumKey, station.ISRCKey, station.ExtraKeys, isSetKey)
+	keys := map[string][]interface{}{"artist": station.ArtistKey, "title": station.TitleKey}
+	for field, key := range metadataKeys {
+		keys[field] = key
 	}
+	for field, key := range keys {
+		if err := validateKey(key); err != nil {
+			return nil, fmt.Errorf("invalid %s key %v: %v", field, key, err)
+		}
+	}
+
+	return &JSONScraper{
+		BaseScraper:  NewBaseScraper(station),
+		ArtistKey:    station.ArtistKey,
+		TitleKey:     station.TitleKey,
+		MetadataKeys: metadataKeys,
+	}, nil
+}
 
+func (s *JSONScraper) GetNowPlaying(ctx context.Context) (*Song, error) {
+	return firstSong(s.GetHistory(ctx))
+}
+
+func (s *JSONScraper) GetHistory(ctx context.Context) ([]*Song, error) {
+	return s.fetch(ctx, s.parse)
+}
+
+func (s *JSONScraper) parse(body io.Reader) ([]*Song, error) {
 	var data interface{}
-	err = json.NewDecoder(resp.Body).Decode(&data)
+	err := json.NewDecoder(body).Decode(&data)
 	if err != nil {
 		return nil, err
 	}
 
-	artist, err := getValueFromKey(data, s.ArtistKey)
+	artists, err := getValuesFromKey(data, s.ArtistKey)
 	if err != nil {
 		return nil, err
 	}
 
-	title, err := getValueFromKey(data, s.TitleKey)
+	titles, err := getValuesFromKey(data, s.TitleKey)
 	if err != nil {
 		return nil, err
 	}
 
-	artistStr, ok := artist.(string)
-	if !ok || artistStr == "" {
-		return nil, fmt.Errorf("artist key does not contain a string")
+	s.traceMatch("artist", fmt.Sprint(s.ArtistKey), traceValues(artists)...)
+	s.traceMatch("title", fmt.Sprint(s.TitleKey), traceValues(titles)...)
+
+	if len(artists) != len(titles) {
+		return nil, fmt.Errorf("artist key matched %d entries but title key matched %d", len(artists), len(titles))
 	}
 
-	titleStr, ok := title.(string)
-	if !ok || titleStr == "" {
-		return nil, fmt.Errorf("title key does not contain a string")
+	metadata := make(map[string][]interface{})
+	for field, key := range s.MetadataKeys {
+		values, err := getValuesFromKey(data, key)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s key: %v", field, err)
+		}
+		s.traceMatch(field, fmt.Sprint(key), traceValues(values)...)
+		// Metadata is optional, so a field that doesn't line up with the songs is dropped instead of failing the station
+		if len(values) != len(artists) {
+			utils.Logger.Debugf("Ignoring %s of %s: artist key matched %d entries but %s key matched %d", field, s.URL, len(artists), field, len(values))
+			continue
+		}
+		metadata[field] = values
 	}
 
-	return &Song{
-		Artist: artistStr,
-		Title:  titleStr,
-	}, nil
+	var songs []*Song
+	for i := range artists {
+		artistStr, ok := artists[i].(string)
+		if !ok || artistStr == "" {
+			continue
+		}
+
+		titleStr, ok := titles[i].(string)
+		if !ok || titleStr == "" {
+			continue
+		}
+
+		song := &Song{
+			Artist: artistStr,
+			Title:  titleStr,
+		}
+		for field, values := range metadata {
+			setMetadata(song, field, values[i])
+		}
+		songs = append(songs, song)
+	}
+
+	if len(songs) == 0 {
+		return nil, fmt.Errorf("artist or title key does not contain a string")
+	}
+
+	return songs, nil
 }
 
-func getValueFromKey(data interface{}, keys []interface{}) (interface{}, error) {
+// getValuesFromKey follows the key path through the decoded JSON. Every Wildcard in the path
+// fans out over an array; entries that don't contain the rest of the path yield nil so that
+// artist and title values stay aligned.
+func getValuesFromKey(data interface{}, keys []interface{}) ([]interface{}, error) {
 	var value interface{} = data
+	for i, key := range keys {
+		if key == Wildcard {
+			a, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("wildcard key used on non-array value: %T", value)
+			}
+
+			var values []interface{}
+			for _, item := range a {
+				itemValues, err := getValuesFromKey(item, keys[i+1:])
+				if err != nil {
+					values = append(values, nil)
+					continue
+				}
+				values = append(values, itemValues...)
+			}
+			return values, nil
+		}
+
+		var err error
+		value, err = getValue(value, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []interface{}{value}, nil
+}
+
+// traceValues formats matched JSON values for a trace
+func traceValues(values []interface{}) []string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = fmt.Sprint(value)
+	}
+	return formatted
+}
+
+// validateKey checks that a key path only contains object keys, array indexes and wildcards
+func validateKey(keys []interface{}) error {
 	for _, key := range keys {
 		switch key := key.(type) {
 		case string:
-			if m, ok := value.(map[string]interface{}); ok {
-				value = m[key]
-			} else {
-				return nil, fmt.Errorf("invalid key type: %T", key)
-			}
 		case int:
-			if a, ok := value.([]interface{}); ok {
-				value = a[key]
-			} else {
-				return nil, fmt.Errorf("invalid key type: %T", key)
+			if key < 0 {
+				return fmt.Errorf("negative index %d", key)
 			}
 		case float64:
-			index := int(key)
-			if a, ok := value.([]interface{}); ok {
-				value = a[index]
-			} else {
-				return nil, fmt.Errorf("invalid key type: %T", key)
+			if key < 0 || key != float64(int(key)) {
+				return fmt.Errorf("index %v is not a non-negative integer", key)
 			}
 		default:
-			return nil, fmt.Errorf("invalid key type: %T", key)
+			return fmt.Errorf("invalid key type: %T", key)
 		}
 	}
-	return value, nil
+	return nil
+}
+
+func getValue(value interface{}, key interface{}) (interface{}, error) {
+	switch key := key.(type) {
+	case string:
+		if m, ok := value.(map[string]interface{}); ok {
+			return m[key], nil
+		}
+		return nil, fmt.Errorf("invalid key type: %T", key)
+	case int:
+		return getIndex(value, key)
+	case float64:
+		return getIndex(value, int(key))
+	default:
+		return nil, fmt.Errorf("invalid key type: %T", key)
+	}
+}
+
+func getIndex(value interface{}, index int) (interface{}, error) {
+	a, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid key type: %T", index)
+	}
+	if index < 0 || index >= len(a) {
+		return nil, fmt.Errorf("index %d out of range (length %d)", index, len(a))
+	}
+	return a[index], nil
 }
@@ -0,0 +1,127 @@
+package scraper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"radio-to-spotify/utils"
+)
+
+const testPlaylist = `{
+	"station": {"name": "Test FM", "album": "Not a list"},
+	"songs": [
+		{"artist": "Queen", "title": "Under Pressure", "length": 248, "meta": {"album": "Hot Space"}},
+		{"artist": "Jingle"},
+		{"artist": "Daft Punk", "title": "One More Time", "length": "5:20"},
+		{"artist": 42, "title": "Not a name"},
+		{"artist": "Dua Lipa", "title": "Houdini", "meta": {"album": "Radical Optimism"}}
+	]
+}`
+
+func TestGetValuesFromKey(t *testing.T) {
+	data := map[string]interface{}{
+		"songs": []interface{}{
+			map[string]interface{}{"artists": []interface{}{"A", "B"}},
+			map[string]interface{}{"title": "no artists"},
+			map[string]interface{}{"artists": []interface{}{"C"}},
+		},
+		"current": map[string]interface{}{"artist": "D"},
+	}
+
+	tests := []struct {
+		name    string
+		keys    []interface{}
+		want    []interface{}
+		wantErr bool
+	}{
+		{name: "plain key", keys: []interface{}{"current", "artist"}, want: []interface{}{"D"}},
+		{name: "index from JSON", keys: []interface{}{"songs", float64(2), "artists", 0}, want: []interface{}{"C"}},
+		{name: "wildcard keeps missing entries", keys: []interface{}{"songs", Wildcard, "artists", 0}, want: []interface{}{"A", nil, "C"}},
+		{name: "nested wildcards", keys: []interface{}{"songs", Wildcard, "artists", Wildcard}, want: []interface{}{"A", "B", nil, "C"}},
+		{name: "wildcard on object", keys: []interface{}{"current", Wildcard}, wantErr: true},
+		{name: "index out of range", keys: []interface{}{"songs", 3}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getValuesFromKey(data, tt.keys)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("getValuesFromKey(%v) = %v, want error", tt.keys, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getValuesFromKey(%v): %v", tt.keys, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getValuesFromKey(%v) = %v, want %v", tt.keys, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONScraperWildcards(t *testing.T) {
+	s, err := NewJSONScraper(utils.Station{
+		ID:          "test",
+		Type:        "json",
+		ArtistKey:   []interface{}{"songs", Wildcard, "artist"},
+		TitleKey:    []interface{}{"songs", Wildcard, "title"},
+		DurationKey: []interface{}{"songs", Wildcard, "length"},
+		AlbumKey:    []interface{}{"station", "album"}, // Doesn't line up with the songs, so it is dropped
+		ExtraKeys:   map[string][]interface{}{"albumTitle": {"songs", Wildcard, "meta", "album"}},
+	})
+	if err != nil {
+		t.Fatalf("NewJSONScraper: %v", err)
+	}
+
+	songs, err := s.parse(strings.NewReader(testPlaylist))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	// Entries without a title or with an artist that isn't a string are skipped
+	want := []Song{
+		{Artist: "Queen", Title: "Under Pressure", Duration: 248 * time.Second, Extra: map[string]string{"albumTitle": "Hot Space"}},
+		{Artist: "Daft Punk", Title: "One More Time", Duration: 5*time.Minute + 20*time.Second},
+		{Artist: "Dua Lipa", Title: "Houdini", Extra: map[string]string{"albumTitle": "Radical Optimism"}},
+	}
+	if len(songs) != len(want) {
+		t.Fatalf("parse returned %d songs, want %d: %v", len(songs), len(want), songs)
+	}
+	for i, song := range songs {
+		if !reflect.DeepEqual(*song, want[i]) {
+			t.Errorf("song %d = %+v, want %+v", i, *song, want[i])
+		}
+	}
+}
+
+func TestJSONScraperMismatchedTitles(t *testing.T) {
+	s, err := NewJSONScraper(utils.Station{
+		ID:        "test",
+		Type:      "json",
+		ArtistKey: []interface{}{"songs", Wildcard, "artist"},
+		TitleKey:  []interface{}{"songs", 0, "title"},
+	})
+	if err != nil {
+		t.Fatalf("NewJSONScraper: %v", err)
+	}
+
+	if _, err := s.parse(strings.NewReader(testPlaylist)); err == nil || !strings.Contains(err.Error(), "title key matched 1") {
+		t.Fatalf("parse error = %v, want a mismatch error", err)
+	}
+}
+
+func TestNewJSONScraperInvalidKey(t *testing.T) {
+	_, err := NewJSONScraper(utils.Station{
+		ID:        "test",
+		Type:      "json",
+		ArtistKey: []interface{}{"songs", float64(-1), "artist"},
+		TitleKey:  []interface{}{"songs", 1.5, "title"},
+	})
+	if err == nil {
+		t.Fatal("NewJSONScraper accepted negative and fractional indexes")
+	}
+}
@@ -0,0 +1,187 @@
+package scraper
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Names of the optional song metadata fields. Selectors for any other name end up in Song.Extra.
+const (
+	FieldPlayedAt = "playedAt"
+	FieldDuration = "duration"
+	FieldAlbum    = "album"
+	FieldISRC     = "isrc"
+)
+
+// Layouts tried in order when a feed provides the play time as a string
+var timestampLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+var isoDurationRegex = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?$`)
+
+// metadataSelectors collects the configured selectors of the optional metadata fields, keyed by field name
+func metadataSelectors[T any](playedAt, duration, album, isrc T, extra map[string]T, isSet func(T) bool) map[string]T {
+	selectors := make(map[string]T)
+	for name, selector := range extra {
+		if isSet(selector) {
+			selectors[name] = selector
+		}
+	}
+	for name, selector := range map[string]T{
+		FieldPlayedAt: playedAt,
+		FieldDuration: duration,
+		FieldAlbum:    album,
+		FieldISRC:     isrc,
+	} {
+		if isSet(selector) {
+			selectors[name] = selector
+		}
+	}
+	return selectors
+}
+
+func isSetString(selector string) bool {
+	return selector != ""
+}
+
+func isSetKey(key []interface{}) bool {
+	return len(key) > 0
+}
+
+// setMetadata stores a scraped metadata value on the song. Values that can't be interpreted are ignored.
+func setMetadata(song *Song, field string, value interface{}) {
+	if value == nil {
+		return
+	}
+
+	switch field {
+	case FieldPlayedAt:
+		song.PlayedAt = parseTimestamp(value)
+	case FieldDuration:
+		song.Duration = parseDuration(value)
+	case FieldAlbum:
+		song.Album = strings.TrimSpace(fmt.Sprint(value))
+	case FieldISRC:
+		song.ISRC = strings.ToUpper(strings.TrimSpace(fmt.Sprint(value)))
+	default:
+		str := strings.TrimSpace(fmt.Sprint(value))
+		if str == "" {
+			return
+		}
+		if song.Extra == nil {
+			song.Extra = make(map[string]string)
+		}
+		song.Extra[field] = str
+	}
+}
+
+// parseTimestamp converts a timestamp from a feed into a time. Strings are parsed with the common
+// layouts above, numbers are treated as Unix time in seconds or milliseconds. Returns nil if the
+// value can't be interpreted.
+func parseTimestamp(value interface{}) *time.Time {
+	switch v := value.(type) {
+	case string:
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return nil
+		}
+		for _, layout := range timestampLayouts {
+			// Layouts without a zone are interpreted in local time, like the broadcaster's clock
+			if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+				return &t
+			}
+		}
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return unixTimestamp(n)
+		}
+	case float64:
+		return unixTimestamp(v)
+	case int64:
+		return unixTimestamp(float64(v))
+	case int:
+		return unixTimestamp(float64(v))
+	}
+	return nil
+}
+
+func unixTimestamp(n float64) *time.Time {
+	if n <= 0 {
+		return nil
+	}
+	var t time.Time
+	// Anything beyond the year 2286 in seconds is most likely milliseconds
+	if n > 1e10 {
+		t = time.UnixMilli(int64(n))
+	} else {
+		t = time.Unix(int64(n), 0)
+	}
+	return &t
+}
+
+// parseDuration converts a song length from a feed into a duration. Supported are numbers of seconds or
+// milliseconds, "m:ss" and "h:mm:ss" clock notation, ISO 8601 ("PT3M45S") and Go durations ("3m45s").
+// Returns 0 if the value can't be interpreted.
+func parseDuration(value interface{}) time.Duration {
+	switch v := value.(type) {
+	case string:
+		v = strings.TrimSpace(v)
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return numericDuration(n)
+		}
+		if d, ok := parseClockDuration(v); ok {
+			return d
+		}
+		if matches := isoDurationRegex.FindStringSubmatch(v); matches != nil && v != "PT" {
+			hours, _ := strconv.Atoi(matches[1])
+			minutes, _ := strconv.Atoi(matches[2])
+			seconds, _ := strconv.ParseFloat(matches[3], 64)
+			return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second))
+		}
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	case float64:
+		return numericDuration(v)
+	case int64:
+		return numericDuration(float64(v))
+	case int:
+		return numericDuration(float64(v))
+	}
+	return 0
+}
+
+func numericDuration(n float64) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	// No song is longer than 10000 seconds, but every song is longer than 10000 milliseconds
+	if n >= 10000 {
+		return time.Duration(n) * time.Millisecond
+	}
+	return time.Duration(n * float64(time.Second))
+}
+
+func parseClockDuration(s string) (time.Duration, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+
+	var d time.Duration
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		d = d*60 + time.Duration(n)
+	}
+	return d * time.Second, d > 0
+}
@@ -0,0 +1,165 @@
+package scraper
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"radio-to-spotify/utils"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	utc := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	// Layouts without a zone are read in local time
+	local := time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  *time.Time
+	}{
+		{name: "RFC 3339", value: "2024-05-01T10:00:00Z", want: &utc},
+		{name: "RFC 3339 with offset", value: "2024-05-01T12:00:00+02:00", want: &utc},
+		{name: "RFC 1123 with offset", value: "Wed, 01 May 2024 10:00:00 +0000", want: &utc},
+		{name: "without zone", value: "2024-05-01T10:00:00", want: &local},
+		{name: "date and time", value: " 2024-05-01 10:00:00 ", want: &local},
+		{name: "seconds", value: float64(1714557600), want: &utc},
+		{name: "milliseconds", value: float64(1714557600000), want: &utc},
+		{name: "seconds as string", value: "1714557600", want: &utc},
+		{name: "milliseconds as string", value: "1714557600000", want: &utc},
+		{name: "int64", value: int64(1714557600), want: &utc},
+		{name: "int", value: 1714557600, want: &utc},
+		{name: "empty", value: ""},
+		{name: "text", value: "yesterday"},
+		{name: "zero", value: float64(0)},
+		{name: "negative", value: -5},
+		{name: "other type", value: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseTimestamp(tt.value)
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("parseTimestamp(%v) = %v, want nil", tt.value, got)
+			case tt.want != nil && (got == nil || !got.Equal(*tt.want)):
+				t.Errorf("parseTimestamp(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  time.Duration
+	}{
+		{name: "seconds", value: float64(248), want: 248 * time.Second},
+		{name: "fractional seconds", value: 248.5, want: 248*time.Second + 500*time.Millisecond},
+		{name: "seconds as string", value: " 248 ", want: 248 * time.Second},
+		{name: "int", value: 248, want: 248 * time.Second},
+		{name: "int64", value: int64(248), want: 248 * time.Second},
+		{name: "milliseconds", value: float64(248000), want: 248 * time.Second},
+		{name: "milliseconds as string", value: "248000", want: 248 * time.Second},
+		{name: "minutes and seconds", value: "3:45", want: 3*time.Minute + 45*time.Second},
+		{name: "hours, minutes and seconds", value: "1:02:03", want: time.Hour + 2*time.Minute + 3*time.Second},
+		{name: "ISO 8601", value: "PT3M45S", want: 3*time.Minute + 45*time.Second},
+		{name: "ISO 8601 with hours", value: "PT1H2M3S", want: time.Hour + 2*time.Minute + 3*time.Second},
+		{name: "ISO 8601 fractional seconds", value: "PT3.5S", want: 3*time.Second + 500*time.Millisecond},
+		{name: "Go duration", value: "3m45s", want: 3*time.Minute + 45*time.Second},
+		{name: "empty", value: ""},
+		{name: "empty ISO 8601", value: "PT"},
+		{name: "zero clock", value: "0:00"},
+		{name: "negative clock", value: "-3:45"},
+		{name: "invalid clock", value: "3:xx"},
+		{name: "too many clock parts", value: "1:02:03:04"},
+		{name: "zero", value: float64(0)},
+		{name: "negative", value: -1},
+		{name: "text", value: "soon"},
+		{name: "other type", value: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDuration(tt.value); got != tt.want {
+				t.Errorf("parseDuration(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// feedParser is implemented by the scrapers that parse a whole feed
+type feedParser interface {
+	parse(body io.Reader) ([]*Song, error)
+}
+
+func TestMetadataCountMismatch(t *testing.T) {
+	// Both feeds have two songs, but only one duration, which can't be assigned to a song and is dropped
+	jsonFeed := `{"songs": [
+		{"artist": "Queen", "title": "Under Pressure", "playedAt": "2024-05-01T10:04:00Z", "length": 248},
+		{"artist": "Daft Punk", "title": "One More Time", "playedAt": "2024-05-01T10:00:00Z"}
+	]}`
+	xmlFeed := `<songs>
+		<song><artist>Queen</artist><title>Under Pressure</title><playedAt>2024-05-01T10:04:00Z</playedAt><length>248</length></song>
+		<song><artist>Daft Punk</artist><title>One More Time</title><playedAt>2024-05-01T10:00:00Z</playedAt></song>
+	</songs>`
+
+	tests := []struct {
+		name    string
+		station utils.Station
+		feed    string
+	}{
+		{
+			name: "json",
+			station: utils.Station{
+				ID:          "test",
+				Type:        "json",
+				ArtistKey:   []interface{}{"songs", Wildcard, "artist"},
+				TitleKey:    []interface{}{"songs", Wildcard, "title"},
+				PlayedAtKey: []interface{}{"songs", Wildcard, "playedAt"},
+				DurationKey: []interface{}{"songs", 0, "length"},
+			},
+			feed: jsonFeed,
+		},
+		{
+			name: "xml",
+			station: utils.Station{
+				ID:            "test",
+				Type:          "xml",
+				ArtistXPath:   "//song/artist",
+				TitleXPath:    "//song/title",
+				PlayedAtXPath: "//song/playedAt",
+				DurationXPath: "//song/length",
+			},
+			feed: xmlFeed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(tt.station)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			songs, err := s.(feedParser).parse(strings.NewReader(tt.feed))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if len(songs) != 2 {
+				t.Fatalf("parse returned %d songs, want 2", len(songs))
+			}
+
+			// The play times line up with the songs and are kept
+			for i, want := range []time.Time{time.Date(2024, 5, 1, 10, 4, 0, 0, time.UTC), time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)} {
+				if songs[i].PlayedAt == nil || !songs[i].PlayedAt.Equal(want) {
+					t.Errorf("song %d played at %v, want %v", i, songs[i].PlayedAt, want)
+				}
+				if songs[i].Duration != 0 {
+					t.Errorf("song %d has duration %v, want the mismatched durations dropped", i, songs[i].Duration)
+				}
+			}
+		})
+	}
+}
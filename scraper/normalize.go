@@ -0,0 +1,247 @@
+package scraper
+
+import (
+	"html"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"radio-to-spotify/utils"
+)
+
+var globalRules = struct {
+	mu    sync.RWMutex
+	rules utils.NormalizeRules
+}{}
+
+// Compiled normalizers by station ID, rebuilt when the rules of the station or the global rules change
+var normalizers = struct {
+	mu          sync.Mutex
+	normalizers map[string]*cachedNormalizer
+}{normalizers: make(map[string]*cachedNormalizer)}
+
+type cachedNormalizer struct {
+	global     utils.NormalizeRules
+	station    utils.NormalizeRules // Copy of the rules of the station, empty if it has none
+	normalizer *normalizer
+}
+
+var (
+	// Runs of whitespace and control characters, collapsed to a single space
+	spaceRegex = regexp.MustCompile(`[\s\p{Cc}\x{00a0}]+`)
+	// Featured artists in a title, in brackets ("Song (feat. Artist)") or at the end ("Song ft. Artist")
+	featuredRegex = regexp.MustCompile(`(?i)\s*[(\[]\s*(?:feat\.?|ft\.?|featuring)\s+([^)\]]+)[)\]]|\s+(?:feat\.?|ft\.?|featuring)\s+(.+)$`)
+)
+
+// SetNormalizeRules sets the global normalization rules, applied to the songs of every station before its own rules
+func SetNormalizeRules(rules utils.NormalizeRules) {
+	globalRules.mu.Lock()
+	defer globalRules.mu.Unlock()
+
+	globalRules.rules = rules
+}
+
+// normalizer cleans up the artists and titles of a station. The steps run in this order: decoding HTML entities,
+// collapsing whitespace, the artist separators, extracting featured artists, stripping suffixes, fixing the case and
+// the replace rules. Every step is off unless it is configured, so songs are stored as scraped by default.
+type normalizer struct {
+	decodeEntities     bool
+	collapseWhitespace bool
+	fixCase            bool
+	extractFeatured    bool
+	artistSeparators   []string
+	suffixes           *regexp.Regexp // nil without suffixes
+	replace            []replaceRule
+}
+
+type replaceRule struct {
+	field       string
+	regex       *regexp.Regexp
+	replacement string
+}
+
+// newNormalizer combines the global rules with the rules of a station. Settings of the station override the global
+// ones, its lists are applied after the global lists.
+func newNormalizer(global utils.NormalizeRules, stationRules *utils.NormalizeRules) (*normalizer, error) {
+	setting := func(global, station *bool) bool {
+		if station != nil {
+			return *station
+		}
+		return global != nil && *global
+	}
+
+	n := &normalizer{
+		decodeEntities:     setting(global.DecodeEntities, stationRules.DecodeEntities),
+		collapseWhitespace: setting(global.CollapseWhitespace, stationRules.CollapseWhitespace),
+		fixCase:            setting(global.FixCase, stationRules.FixCase),
+		extractFeatured:    setting(global.ExtractFeatured, stationRules.ExtractFeatured),
+		artistSeparators:   append(append([]string{}, global.ArtistSeparators...), stationRules.ArtistSeparators...),
+	}
+
+	var suffixes []string
+	for _, suffix := range append(append([]string{}, global.StripSuffixes...), stationRules.StripSuffixes...) {
+		suffixes = append(suffixes, regexp.QuoteMeta(suffix))
+	}
+	if len(suffixes) > 0 {
+		alternatives := strings.Join(suffixes, "|")
+		n.suffixes = regexp.MustCompile(`(?i)\s*(?:[(\[]\s*(?:` + alternatives + `)\s*[)\]]|\s-\s+(?:` + alternatives + `))\s*$`)
+	}
+
+	for _, rule := range append(append([]utils.ReplaceRule{}, global.Replace...), stationRules.Replace...) {
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		n.replace = append(n.replace, replaceRule{field: rule.Field, regex: regex, replacement: rule.Replacement})
+	}
+	return n, nil
+}
+
+// getNormalizer returns the normalizer of a station, compiling it only if the station is new or its rules changed,
+// e.g. after the stations file was reloaded
+func getNormalizer(station *utils.Station) (*normalizer, error) {
+	globalRules.mu.RLock()
+	global := globalRules.rules
+	globalRules.mu.RUnlock()
+
+	normalizers.mu.Lock()
+	defer normalizers.mu.Unlock()
+
+	var stationRules utils.NormalizeRules
+	if station.Normalize != nil {
+		stationRules = *station.Normalize
+	}
+
+	cached, exists := normalizers.normalizers[station.ID]
+	if exists && reflect.DeepEqual(cached.global, global) && reflect.DeepEqual(cached.station, stationRules) {
+		return cached.normalizer, nil
+	}
+
+	n, err := newNormalizer(global, &stationRules)
+	if err != nil {
+		return nil, err
+	}
+	normalizers.normalizers[station.ID] = &cachedNormalizer{global: global, station: stationRules, normalizer: n}
+	return n, nil
+}
+
+// normalizeSongs applies the normalization rules of a station to its songs
+func normalizeSongs(station *utils.Station, songs []*Song) error {
+	n, err := getNormalizer(station)
+	if err != nil {
+		return err
+	}
+	for _, song := range songs {
+		n.normalize(song)
+	}
+	return nil
+}
+
+// normalize cleans up the artist and title of a song and keeps the scraped values in RawArtist and RawTitle if they
+// changed. The scraped values are kept if normalization would leave the artist or title empty.
+func (n *normalizer) normalize(song *Song) {
+	artist, title := n.clean(song.Artist), n.clean(song.Title)
+
+	for _, separator := range n.artistSeparators {
+		artist = strings.ReplaceAll(artist, separator, " & ")
+	}
+
+	var featured string
+	if n.extractFeatured {
+		title, featured = extractFeatured(artist, title)
+	}
+
+	if n.suffixes != nil {
+		for stripped := n.suffixes.ReplaceAllString(title, ""); stripped != title; stripped = n.suffixes.ReplaceAllString(title, "") {
+			title = stripped
+		}
+	}
+
+	// Each part is fixed on its own, so a suffix or "feat." in lower case doesn't keep the rest in caps
+	if n.fixCase {
+		artist, title, featured = fixCase(artist), fixCase(title), fixCase(featured)
+	}
+	if featured != "" {
+		artist += " feat. " + featured
+	}
+
+	for _, rule := range n.replace {
+		if rule.field != "title" {
+			artist = rule.regex.ReplaceAllString(artist, rule.replacement)
+		}
+		if rule.field != "artist" {
+			title = rule.regex.ReplaceAllString(title, rule.replacement)
+		}
+	}
+
+	if n.collapseWhitespace {
+		artist, title = collapseSpaces(artist), collapseSpaces(title)
+	}
+	if strings.TrimSpace(artist) == "" || strings.TrimSpace(title) == "" {
+		utils.Logger.Debugf("Keeping %s - %s, normalization would leave the artist or title empty", song.Artist, song.Title)
+		return
+	}
+
+	if artist != song.Artist {
+		song.RawArtist, song.Artist = song.Artist, artist
+	}
+	if title != song.Title {
+		song.RawTitle, song.Title = song.Title, title
+	}
+}
+
+func (n *normalizer) clean(value string) string {
+	if n.decodeEntities {
+		value = html.UnescapeString(value)
+	}
+	if n.collapseWhitespace {
+		value = collapseSpaces(value)
+	}
+	return value
+}
+
+func collapseSpaces(value string) string {
+	return strings.TrimSpace(spaceRegex.ReplaceAllString(value, " "))
+}
+
+// extractFeatured removes featured artists from the title and returns them, unless the artist already names them
+func extractFeatured(artist, title string) (string, string) {
+	match := featuredRegex.FindStringSubmatch(title)
+	if match == nil {
+		return title, ""
+	}
+	featured := strings.TrimSpace(match[1] + match[2])
+	title = strings.TrimSpace(featuredRegex.ReplaceAllString(title, ""))
+	if strings.Contains(strings.ToLower(artist), strings.ToLower(featured)) {
+		featured = ""
+	}
+	return title, featured
+}
+
+// fixCase title cases a value written in all caps, e.g. "DON'T STOP ME NOW" becomes "Don't Stop Me Now". Values with
+// lower case letters are left alone. Names that are meant to be in caps, like "AC/DC", can be restored by a replace
+// rule, which runs afterwards.
+func fixCase(value string) string {
+	hasUpper := false
+	for _, r := range value {
+		if unicode.IsLower(r) {
+			return value
+		}
+		hasUpper = hasUpper || unicode.IsUpper(r)
+	}
+	if !hasUpper {
+		return value
+	}
+
+	runes := []rune(value)
+	for i, r := range runes {
+		// Words start after anything but letters, digits and apostrophes
+		wordStart := i == 0 || !(unicode.IsLetter(runes[i-1]) || unicode.IsDigit(runes[i-1]) || runes[i-1] == '\'' || runes[i-1] == '’')
+		if !wordStart {
+			runes[i] = unicode.ToLower(r)
+		}
+	}
+	return string(runes)
+}
@@ -0,0 +1,233 @@
+package scraper
+
+import (
+	"testing"
+
+	"radio-to-spotify/utils"
+)
+
+func enabled() *bool {
+	b := true
+	return &b
+}
+
+func disabled() *bool {
+	b := false
+	return &b
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name          string
+		rules         utils.NormalizeRules
+		artist, title string
+		wantArtist    string
+		wantTitle     string
+	}{
+		{
+			name:       "no rules",
+			artist:     " Simon &amp;  Garfunkel ",
+			title:      "MRS. ROBINSON",
+			wantArtist: " Simon &amp;  Garfunkel ",
+			wantTitle:  "MRS. ROBINSON",
+		},
+		{
+			name:       "entities and whitespace",
+			rules:      utils.NormalizeRules{DecodeEntities: enabled(), CollapseWhitespace: enabled()},
+			artist:     " Simon &amp;\t Garfunkel ",
+			title:      "Mrs. Robinson",
+			wantArtist: "Simon & Garfunkel",
+			wantTitle:  "Mrs. Robinson",
+		},
+		{
+			name:       "artist separators",
+			rules:      utils.NormalizeRules{ArtistSeparators: []string{" / ", " x "}},
+			artist:     "Queen / David Bowie x Annie Lennox",
+			title:      "Under Pressure",
+			wantArtist: "Queen & David Bowie & Annie Lennox",
+			wantTitle:  "Under Pressure",
+		},
+		{
+			name:       "featured in brackets",
+			rules:      utils.NormalizeRules{ExtractFeatured: enabled()},
+			artist:     "Calvin Harris",
+			title:      "One Kiss (feat. Dua Lipa)",
+			wantArtist: "Calvin Harris feat. Dua Lipa",
+			wantTitle:  "One Kiss",
+		},
+		{
+			name:       "featured at the end",
+			rules:      utils.NormalizeRules{ExtractFeatured: enabled()},
+			artist:     "Calvin Harris",
+			title:      "One Kiss ft. Dua Lipa",
+			wantArtist: "Calvin Harris feat. Dua Lipa",
+			wantTitle:  "One Kiss",
+		},
+		{
+			name:       "featured already named",
+			rules:      utils.NormalizeRules{ExtractFeatured: enabled()},
+			artist:     "Calvin Harris & Dua Lipa",
+			title:      "One Kiss (featuring Dua Lipa)",
+			wantArtist: "Calvin Harris & Dua Lipa",
+			wantTitle:  "One Kiss",
+		},
+		{
+			name:       "suffixes",
+			rules:      utils.NormalizeRules{StripSuffixes: []string{"Radio Edit", "Neu"}},
+			artist:     "Daft Punk",
+			title:      "One More Time (Radio Edit) [NEU]",
+			wantArtist: "Daft Punk",
+			wantTitle:  "One More Time",
+		},
+		{
+			name:       "suffix after a dash",
+			rules:      utils.NormalizeRules{StripSuffixes: []string{"Radio Edit"}},
+			artist:     "Daft Punk",
+			title:      "One More Time - Radio Edit",
+			wantArtist: "Daft Punk",
+			wantTitle:  "One More Time",
+		},
+		{
+			name:       "suffix inside the title",
+			rules:      utils.NormalizeRules{StripSuffixes: []string{"Edit"}},
+			artist:     "The Edit",
+			title:      "Edit Me",
+			wantArtist: "The Edit",
+			wantTitle:  "Edit Me",
+		},
+		{
+			name:       "case",
+			rules:      utils.NormalizeRules{FixCase: enabled(), ExtractFeatured: enabled()},
+			artist:     "CALVIN HARRIS",
+			title:      "ONE KISS (FEAT. DUA LIPA)",
+			wantArtist: "Calvin Harris feat. Dua Lipa",
+			wantTitle:  "One Kiss",
+		},
+		{
+			name:       "mixed case is kept",
+			rules:      utils.NormalizeRules{FixCase: enabled()},
+			artist:     "deadmau5",
+			title:      "DON'T STOP ME NOW",
+			wantArtist: "deadmau5",
+			wantTitle:  "Don't Stop Me Now",
+		},
+		{
+			name: "replace after fixing the case",
+			rules: utils.NormalizeRules{FixCase: enabled(), Replace: []utils.ReplaceRule{
+				{Field: "artist", Pattern: `^Ac/Dc\b`, Replacement: "AC/DC"},
+				{Field: "title", Pattern: `(?i)^hells`, Replacement: "Hell's"},
+			}},
+			artist:     "AC/DC",
+			title:      "HELLS BELLS",
+			wantArtist: "AC/DC",
+			wantTitle:  "Hell's Bells",
+		},
+		{
+			name:       "replace in both fields",
+			rules:      utils.NormalizeRules{Replace: []utils.ReplaceRule{{Pattern: `\s*\*+$`, Replacement: ""}}},
+			artist:     "Queen*",
+			title:      "Bohemian Rhapsody **",
+			wantArtist: "Queen",
+			wantTitle:  "Bohemian Rhapsody",
+		},
+		{
+			name:       "empty result keeps the scraped values",
+			rules:      utils.NormalizeRules{Replace: []utils.ReplaceRule{{Field: "title", Pattern: `.*`, Replacement: ""}}},
+			artist:     "Station",
+			title:      "Jingle",
+			wantArtist: "Station",
+			wantTitle:  "Jingle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := newNormalizer(utils.NormalizeRules{}, &tt.rules)
+			if err != nil {
+				t.Fatalf("newNormalizer: %v", err)
+			}
+			song := &Song{Artist: tt.artist, Title: tt.title}
+			n.normalize(song)
+			if song.Artist != tt.wantArtist || song.Title != tt.wantTitle {
+				t.Fatalf("normalize = %q - %q, want %q - %q", song.Artist, song.Title, tt.wantArtist, tt.wantTitle)
+			}
+
+			// The scraped values are kept only if they changed
+			wantRawArtist, wantRawTitle := tt.artist, tt.title
+			if song.Artist == tt.artist {
+				wantRawArtist = ""
+			}
+			if song.Title == tt.title {
+				wantRawTitle = ""
+			}
+			if song.RawArtist != wantRawArtist || song.RawTitle != wantRawTitle {
+				t.Errorf("raw values = %q - %q, want %q - %q", song.RawArtist, song.RawTitle, wantRawArtist, wantRawTitle)
+			}
+		})
+	}
+}
+
+func TestNormalizeStationRules(t *testing.T) {
+	global := utils.NormalizeRules{
+		DecodeEntities:   enabled(),
+		FixCase:          enabled(),
+		ArtistSeparators: []string{" / "},
+	}
+	station := utils.NormalizeRules{
+		FixCase:          disabled(),
+		ArtistSeparators: []string{" x "},
+	}
+
+	n, err := newNormalizer(global, &station)
+	if err != nil {
+		t.Fatalf("newNormalizer: %v", err)
+	}
+	song := &Song{Artist: "QUEEN / DAVID BOWIE x ANNIE LENNOX", Title: "UNDER PRESSURE &amp; MORE"}
+	n.normalize(song)
+	// The station turns off fixing the case, the separators of both apply
+	if song.Artist != "QUEEN & DAVID BOWIE & ANNIE LENNOX" || song.Title != "UNDER PRESSURE & MORE" {
+		t.Errorf("normalize = %q - %q", song.Artist, song.Title)
+	}
+}
+
+func TestNewNormalizerInvalidPattern(t *testing.T) {
+	rules := utils.NormalizeRules{Replace: []utils.ReplaceRule{{Pattern: "("}}}
+	if _, err := newNormalizer(utils.NormalizeRules{}, &rules); err == nil {
+		t.Fatal("newNormalizer accepted an invalid pattern")
+	}
+}
+
+func TestGetNormalizer(t *testing.T) {
+	t.Cleanup(func() { SetNormalizeRules(utils.NormalizeRules{}) })
+
+	station := &utils.Station{ID: "normalizer-cache", Normalize: &utils.NormalizeRules{StripSuffixes: []string{"Neu"}}}
+	first, err := getNormalizer(station)
+	if err != nil {
+		t.Fatalf("getNormalizer: %v", err)
+	}
+	if again, _ := getNormalizer(station); again != first {
+		t.Error("normalizer was compiled again for unchanged rules")
+	}
+
+	// A reloaded station with equal rules keeps the normalizer
+	reloaded := &utils.Station{ID: station.ID, Normalize: &utils.NormalizeRules{StripSuffixes: []string{"Neu"}}}
+	if again, _ := getNormalizer(reloaded); again != first {
+		t.Error("normalizer was compiled again for equal rules")
+	}
+
+	reloaded.Normalize.StripSuffixes = []string{"Radio Edit"}
+	changed, _ := getNormalizer(reloaded)
+	if changed == first {
+		t.Fatal("normalizer was not compiled again after the station rules changed")
+	}
+	song := &Song{Artist: "Daft Punk", Title: "One More Time (Radio Edit)"}
+	changed.normalize(song)
+	if song.Title != "One More Time" {
+		t.Errorf("changed normalizer left the title %q", song.Title)
+	}
+
+	SetNormalizeRules(utils.NormalizeRules{FixCase: enabled()})
+	if global, _ := getNormalizer(reloaded); global == changed || !global.fixCase {
+		t.Error("normalizer was not compiled again after the global rules changed")
+	}
+}
@@ -2,57 +2,69 @@ package scraper
 
 import (
 	"bufio"
+	"context"
 	"fmt"
-	"net/http"
+	"io"
+	"radio-to-spotify/utils"
 	"regexp"
 )
 
+func init() {
+	Register("plaintext", func(station utils.Station) (Scraper, error) {
+		return NewPlaintextScraper(station)
+	})
+}
+
 type PlaintextScraper struct {
 	*BaseScraper
 	Regex *regexp.Regexp
 }
 
-func NewPlaintextScraper(URL string, regex string) (*PlaintextScraper, error) {
-	compiledRegex, err := regexp.Compile(regex)
+func NewPlaintextScraper(station utils.Station) (*PlaintextScraper, error) {
+	if err := requireFields(requiredField{"regex", station.Regex != ""}); err != nil {
+		return nil, err
+	}
+
+	compiledRegex, err := compileSongRegex(station.Regex)
 	if err != nil {
 		return nil, err
 	}
 
 	return &PlaintextScraper{
-		BaseScraper: NewBaseScraper(URL),
+		BaseScraper: NewBaseScraper(station),
 		Regex:       compiledRegex,
 	}, nil
 }
 
-func (s *PlaintextScraper) GetNowPlaying() (*Song, error) {
-	resp, err := http.Get(s.URL)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+func (s *PlaintextScraper) GetHistory(ctx context.Context) ([]*Song, error) {
+	return s.fetch(ctx, func(body io.Reader) ([]*Song, error) {
+		return singleSong(s.parse(body))
+	})
+}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("error fetching URL: %s, status code: %d", s.URL, resp.StatusCode)
-	}
+func (s *PlaintextScraper) GetNowPlaying(ctx context.Context) (*Song, error) {
+	return firstSong(s.GetHistory(ctx))
+}
 
-	scanner := bufio.NewScanner(resp.Body)
+func (s *PlaintextScraper) parse(body io.Reader) (*Song, error) {
+	scanner := bufio.NewScanner(body)
 	for scanner.Scan() {
 		line := scanner.Text()
 		matches := s.Regex.FindStringSubmatch(line)
-		if len(matches) == 3 {
+		if matches != nil {
+			s.traceMatch("regex", s.Regex.String(), matches...)
+			song := songFromMatch(s.Regex, matches)
+
 			// Clean up artist and title by filtering out invalid values like NULL or \x00
-			artist := cleanString(matches[1])
-			title := cleanString(matches[2])
+			song.Artist = cleanString(song.Artist)
+			song.Title = cleanString(song.Title)
 
 			// Skip if artist or title is empty or contains invalid characters
-			if artist == "" || title == "" {
+			if song.Artist == "" || song.Title == "" {
 				continue
 			}
 
-			return &Song{
-				Artist: artist,
-				Title:  title,
-			}, nil
+			return song, nil
 		}
 	}
 
@@ -63,6 +75,39 @@ func (s *PlaintextScraper) GetNowPlaying() (*Song, error) {
 	return nil, fmt.Errorf("no matches found")
 }
 
+// compileSongRegex compiles a regex that captures artist and title, either as named groups or as the first two groups
+func compileSongRegex(regex string) (*regexp.Regexp, error) {
+	compiledRegex, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %v", regex, err)
+	}
+	if compiledRegex.NumSubexp() < 2 {
+		return nil, fmt.Errorf("regex must capture artist and title: %s", regex)
+	}
+	return compiledRegex, nil
+}
+
+// songFromMatch builds a song from a regex match. The named groups "artist" and "title" are used if present,
+// otherwise the first two groups. Any other named group is stored as metadata of the same name.
+func songFromMatch(re *regexp.Regexp, matches []string) *Song {
+	artistIndex, titleIndex := re.SubexpIndex("artist"), re.SubexpIndex("title")
+	if artistIndex < 0 || titleIndex < 0 {
+		artistIndex, titleIndex = 1, 2
+	}
+
+	song := &Song{
+		Artist: matches[artistIndex],
+		Title:  matches[titleIndex],
+	}
+	for i, name := range re.SubexpNames() {
+		if name == "" || i == artistIndex || i == titleIndex || matches[i] == "" {
+			continue
+		}
+		setMetadata(song, name, matches[i])
+	}
+	return song
+}
+
 // cleanString filters out invalid or unwanted characters like NULL, \x00, etc.
 func cleanString(s string) string {
 	// Define a regex pattern to remove invalid characters like \x00 or NULL
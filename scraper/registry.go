@@ -0,0 +1,93 @@
+package scraper
+
+import (
+	"fmt"
+	"radio-to-spotify/utils"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Constructor creates the scraper for a station definition. It returns an error if the definition is invalid.
+type Constructor func(station utils.Station) (Scraper, error)
+
+var registry = struct {
+	mu           sync.RWMutex
+	constructors map[string]Constructor
+}{constructors: make(map[string]Constructor)}
+
+func init() {
+	// Reject stations of unknown types when the config is loaded instead of on every fetch
+	utils.StationValidator = ValidateStation
+}
+
+// Register makes a scraper type available to stations with the given type. Scraper types register themselves
+// in an init function. It panics if the type is registered twice.
+func Register(stationType string, constructor Constructor) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if constructor == nil {
+		panic("scraper: Register constructor is nil")
+	}
+	if _, exists := registry.constructors[stationType]; exists {
+		panic("scraper: Register called twice for type " + stationType)
+	}
+	registry.constructors[stationType] = constructor
+}
+
+// New creates the scraper for a station with the constructor registered for its type
+func New(station utils.Station) (Scraper, error) {
+	registry.mu.RLock()
+	constructor, exists := registry.constructors[station.Type]
+	registry.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown scraper type %q, known types: %v", station.Type, Types())
+	}
+
+	scraperInstance, err := constructor(station)
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s scraper: %v", station.Type, err)
+	}
+	return scraperInstance, nil
+}
+
+// Types returns the registered scraper types, sorted
+func Types() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	types := make([]string, 0, len(registry.constructors))
+	for stationType := range registry.constructors {
+		types = append(types, stationType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// requiredField is a station field that a scraper type can't work without
+type requiredField struct {
+	Name string
+	Set  bool
+}
+
+// requireFields returns an error naming all required fields that are not set
+func requireFields(fields ...requiredField) error {
+	var missing []string
+	for _, field := range fields {
+		if !field.Set {
+			missing = append(missing, field.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ValidateStation checks that a scraper can be created for the station
+func ValidateStation(station *utils.Station) error {
+	_, err := New(*station)
+	return err
+}
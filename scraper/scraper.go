@@ -1,90 +1,120 @@
 package scraper
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"radio-to-spotify/utils"
 	"sync"
+	"time"
 )
 
 type Song struct {
-	Artist string
-	Title  string
+	Artist   string
+	Title    string
+	PlayedAt *time.Time        `json:",omitempty"` // Play time reported by the station, if available
+	Duration time.Duration     `json:",omitempty"`
+	Album    string            `json:",omitempty"`
+	ISRC     string            `json:",omitempty"`
+	Extra    map[string]string `json:",omitempty"` // Free-form metadata from extra selectors
+	// Artist and title as scraped, only set if normalization changed them
+	RawArtist string `json:",omitempty"`
+	RawTitle  string `json:",omitempty"`
 }
 
+var errNoSongs = errors.New("no songs found")
+
 type Scraper interface {
-	GetNowPlaying() (*Song, error)
+	GetNowPlaying(ctx context.Context) (*Song, error)
+	// GetHistory returns the recently played songs, newest first
+	GetHistory(ctx context.Context) ([]*Song, error)
 }
 
-type BaseScraper struct {
-	URL string
+// firstSong returns the newest song of a history
+func firstSong(songs []*Song, err error) (*Song, error) {
+	if err != nil {
+		return nil, err
+	}
+	if len(songs) == 0 {
+		return nil, errNoSongs
+	}
+	return songs[0], nil
+}
+
+// singleSong wraps a single now playing song as a history for scrapers that only see the current song
+func singleSong(song *Song, err error) ([]*Song, error) {
+	if err != nil {
+		return nil, err
+	}
+	return []*Song{song}, nil
 }
 
-func NewBaseScraper(URL string) *BaseScraper {
-	return &BaseScraper{URL: URL}
+func FetchNowPlaying(ctx context.Context, configHandler *utils.ConfigHandler, stationID string) ([]*utils.Station, []*Song, error) {
+	stations, histories, err := FetchHistory(ctx, configHandler, stationID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	songs := make([]*Song, len(histories))
+	for i, history := range histories {
+		songs[i] = history[0]
+	}
+
+	return stations, songs, nil
 }
 
-func FetchNowPlaying(configHandler *utils.ConfigHandler, stationID string) ([]*utils.Station, []*Song, error) {
+// FetchHistory fetches the recently played songs of every enabled station (or only the given one). Each history is
+// newest first and contains at least one song.
+func FetchHistory(ctx context.Context, configHandler *utils.ConfigHandler, stationID string) ([]*utils.Station, [][]*Song, error) {
 	var stations []utils.Station
 	if stationID != "" {
 		station, err := configHandler.GetStationByID(stationID)
 		if err != nil {
 			return nil, nil, err
 		}
+		if station.Disabled {
+			return nil, nil, fmt.Errorf("station %s is disabled", stationID)
+		}
 		stations = append(stations, *station)
 	} else {
-		stations = configHandler.GetAllStations()
+		stations = configHandler.GetEnabledStations()
 	}
 
 	var wg sync.WaitGroup
 	results := make(chan struct {
 		Station *utils.Station
-		Song    *Song
+		Songs   []*Song
 	}, len(stations))
 
 	for _, station := range stations {
 		wg.Add(1)
-		go fetchStation(&station, &wg, results)
+		go fetchStation(ctx, &station, &wg, results)
 	}
 
 	wg.Wait()
 	close(results)
 
 	var stationSongs []*utils.Station
-	var songs []*Song
+	var histories [][]*Song
 	for result := range results {
 		stationSongs = append(stationSongs, result.Station)
-		songs = append(songs, result.Song)
+		histories = append(histories, result.Songs)
 	}
 
-	return stationSongs, songs, nil
+	return stationSongs, histories, nil
 }
 
-func fetchStation(station *utils.Station, wg *sync.WaitGroup, results chan<- struct {
+func fetchStation(ctx context.Context, station *utils.Station, wg *sync.WaitGroup, results chan<- struct {
 	Station *utils.Station
-	Song    *Song
+	Songs   []*Song
 }) {
 	defer wg.Done()
 
-	var scraperInstance Scraper
-	var err error
-
-	switch station.Type {
-	case "html":
-		scraperInstance = NewHTMLScraper(station.URL, station.ArtistTag, station.TitleTag)
-	case "json":
-		scraperInstance = NewJSONScraper(station.URL, station.ArtistKey, station.TitleKey)
-	case "plaintext":
-		scraperInstance, err = NewPlaintextScraper(station.URL, station.Regex)
-		if err != nil {
-			utils.Logger.Errorf("Error creating plaintext scraper for station %s (%s): %v", station.Name, station.ID, err)
-			return
-		}
-	default:
-		utils.Logger.Errorf("Unknown scraper type for station %s (%s): %s", station.Name, station.ID, station.Type)
+	history, err := FetchStation(ctx, station)
+	if errors.Is(err, ErrBreakerOpen) {
+		utils.Logger.Debugf("Skipping station %s (%s): %v", station.Name, station.ID, err)
 		return
 	}
-
-	utils.Logger.Debugf("Fetching now playing for station: %s (%s)", station.Name, station.ID)
-	nowPlaying, err := scraperInstance.GetNowPlaying()
 	if err != nil {
 		utils.Logger.Warnf("Error fetching now playing for station %s (%s): %v", station.Name, station.ID, err)
 		return
@@ -92,9 +122,28 @@ func fetchStation(station *utils.Station, wg *sync.WaitGroup, results chan<- str
 
 	results <- struct {
 		Station *utils.Station
-		Song    *Song
+		Songs   []*Song
 	}{
 		Station: station,
-		Song:    nowPlaying,
+		Songs:   history,
+	}
+}
+
+// FetchStation fetches the recently played songs of a single station, newest first, and normalizes them. Failed
+// fetches are retried; ErrBreakerOpen is returned while the station is skipped after too many failures.
+func FetchStation(ctx context.Context, station *utils.Station) ([]*Song, error) {
+	scraperInstance, err := New(*station)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Debugf("Fetching now playing for station: %s (%s)", station.Name, station.ID)
+	history, err := fetchWithRetries(ctx, station, scraperInstance)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeSongs(station, history); err != nil {
+		return nil, err
 	}
+	return history, nil
 }
@@ -0,0 +1,67 @@
+package scraper
+
+import (
+	"context"
+	"radio-to-spotify/utils"
+	"time"
+)
+
+// Trace records the details of a single scrape, used to debug station definitions
+type Trace struct {
+	StatusCode int
+	Body       []byte       // Raw (decompressed) response, or the metadata block for icy stations
+	Matches    []TraceMatch // Values matched by each selector, key path or regex
+	Songs      []*Song
+	Duration   time.Duration
+	Err        error
+}
+
+// TraceMatch are the values matched for a field by its selector
+type TraceMatch struct {
+	Field    string
+	Selector string
+	Values   []string
+}
+
+// traceable is implemented by scrapers embedding BaseScraper
+type traceable interface {
+	setTrace(trace *Trace)
+}
+
+// TraceStation scrapes a station once, bypassing retries, the circuit breaker and conditional requests, and records
+// what was received and matched. An error is only returned if no scraper can be created for the station.
+func TraceStation(ctx context.Context, station utils.Station) (*Trace, error) {
+	scraperInstance, err := New(station)
+	if err != nil {
+		return nil, err
+	}
+
+	trace := &Trace{}
+	if t, ok := scraperInstance.(traceable); ok {
+		t.setTrace(trace)
+	}
+
+	start := time.Now()
+	trace.Songs, trace.Err = scraperInstance.GetHistory(ctx)
+	trace.Duration = time.Since(start)
+	if trace.Err == nil {
+		trace.Err = normalizeSongs(&station, trace.Songs)
+	}
+	return trace, nil
+}
+
+func (s *BaseScraper) setTrace(trace *Trace) {
+	s.trace = trace
+}
+
+// traceMatch records the values matched for a field if the scrape is traced
+func (s *BaseScraper) traceMatch(field string, selector string, values ...string) {
+	if s.trace == nil {
+		return
+	}
+	s.trace.Matches = append(s.trace.Matches, TraceMatch{
+		Field:    field,
+		Selector: selector,
+		Values:   values,
+	})
+}
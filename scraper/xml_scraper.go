@@ -0,0 +1,139 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"radio-to-spotify/utils"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+func init() {
+	Register("xml", func(station utils.Station) (Scraper, error) {
+		return NewXMLScraper(station)
+	})
+}
+
+type XMLScraper struct {
+	*BaseScraper
+	ArtistXPath    *xpath.Expr
+	TitleXPath     *xpath.Expr
+	MetadataXPaths map[string]*xpath.Expr // Optional metadata field name -> XPath
+}
+
+func NewXMLScraper(station utils.Station) (*XMLScraper, error) {
+	err := requireFields(
+		requiredField{"artistXPath", station.ArtistXPath != ""},
+		requiredField{"titleXPath", station.TitleXPath != ""},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	compiledArtist, err := xpath.Compile(station.ArtistXPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artist XPath %q: %v", station.ArtistXPath, err)
+	}
+
+	compiledTitle, err := xpath.Compile(station.TitleXPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid title XPath %q: %v", station.TitleXPath, err)
+	}
+
+	metadataXPaths := make(map[string]*xpath.Expr)
+	for field, expr := range metadataSelectors(station.PlayedAtXPath, station.DurationXPath, station.AlbumXPath, station.ISRCXPath, station.ExtraXPaths, isSetString) {
+		metadataXPaths[field], err = xpath.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s XPath %q: %v", field, expr, err)
+		}
+	}
+
+	return &XMLScraper{
+		BaseScraper:    NewBaseScraper(station),
+		ArtistXPath:    compiledArtist,
+		TitleXPath:     compiledTitle,
+		MetadataXPaths: metadataXPaths,
+	}, nil
+}
+
+func (s *XMLScraper) GetNowPlaying(ctx context.Context) (*Song, error) {
+	return firstSong(s.GetHistory(ctx))
+}
+
+// GetHistory returns one song per node matched by the artist and title XPaths, in document order
+func (s *XMLScraper) GetHistory(ctx context.Context) ([]*Song, error) {
+	return s.fetch(ctx, s.parse)
+}
+
+func (s *XMLScraper) parse(body io.Reader) ([]*Song, error) {
+	doc, err := xmlquery.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	artists := xmlquery.QuerySelectorAll(doc, s.ArtistXPath)
+	titles := xmlquery.QuerySelectorAll(doc, s.TitleXPath)
+	s.traceMatch("artist", s.ArtistXPath.String(), traceNodes(artists)...)
+	s.traceMatch("title", s.TitleXPath.String(), traceNodes(titles)...)
+	if len(artists) != len(titles) {
+		return nil, fmt.Errorf("artist XPath matched %d nodes but title XPath matched %d", len(artists), len(titles))
+	}
+
+	metadata := make(map[string][]*xmlquery.Node)
+	for field, expr := range s.MetadataXPaths {
+		nodes := xmlquery.QuerySelectorAll(doc, expr)
+		s.traceMatch(field, expr.String(), traceNodes(nodes)...)
+		// Metadata is optional, so a field that doesn't line up with the songs is dropped instead of failing the station
+		if len(nodes) != len(artists) {
+			utils.Logger.Debugf("Ignoring %s of %s: artist XPath matched %d nodes but %s XPath matched %d", field, s.URL, len(artists), field, len(nodes))
+			continue
+		}
+		metadata[field] = nodes
+	}
+
+	var songs []*Song
+	for i := range artists {
+		artist := innerText(artists[i])
+		title := innerText(titles[i])
+		if artist == "" || title == "" {
+			continue
+		}
+
+		song := &Song{
+			Artist: artist,
+			Title:  title,
+		}
+		for field, nodes := range metadata {
+			if value := innerText(nodes[i]); value != "" {
+				setMetadata(song, field, value)
+			}
+		}
+		songs = append(songs, song)
+	}
+
+	if len(songs) == 0 {
+		return nil, fmt.Errorf("could not find artist or title in XML")
+	}
+
+	return songs, nil
+}
+
+// innerText returns the trimmed text of an element or attribute node, or an empty string if nothing matched
+func innerText(node *xmlquery.Node) string {
+	if node == nil {
+		return ""
+	}
+	return strings.TrimSpace(node.InnerText())
+}
+
+// traceNodes formats matched nodes for a trace
+func traceNodes(nodes []*xmlquery.Node) []string {
+	formatted := make([]string, len(nodes))
+	for i, node := range nodes {
+		formatted[i] = innerText(node)
+	}
+	return formatted
+}
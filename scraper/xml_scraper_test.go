@@ -0,0 +1,171 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"radio-to-spotify/utils"
+)
+
+const testXMLPlaylist = `<?xml version="1.0" encoding="UTF-8"?>
+<radio>
+	<current>
+		<artist> Queen </artist>
+		<title>Under Pressure</title>
+	</current>
+	<history>
+		<track artist="Queen" played="2024-05-01T10:04:00Z">
+			<title>Under Pressure</title>
+			<length>4:08</length>
+			<album>Hot Space</album>
+			<isrc>gbum71029604</isrc>
+			<show>Morning</show>
+		</track>
+		<track artist="" played="2024-05-01T10:03:00Z">
+			<title>Jingle</title>
+			<length>0:10</length>
+			<album/>
+			<isrc/>
+			<show>Morning</show>
+		</track>
+		<track artist="Daft Punk" played="2024-05-01T10:00:00Z">
+			<title>One More Time</title>
+			<length>PT5M20S</length>
+			<album>Discovery</album>
+			<isrc/>
+			<show/>
+		</track>
+	</history>
+</radio>`
+
+// newFeedServer serves a fixed body with the given content type
+func newFeedServer(t *testing.T, contentType, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestXMLScraper(t *testing.T) {
+	playedAt := func(minute int) *time.Time {
+		t := time.Date(2024, 5, 1, 10, minute, 0, 0, time.UTC)
+		return &t
+	}
+
+	tests := []struct {
+		name    string
+		station utils.Station
+		want    []Song
+		wantErr string
+	}{
+		{
+			name:    "single node",
+			station: utils.Station{ArtistXPath: "/radio/current/artist", TitleXPath: "/radio/current/title"},
+			want:    []Song{{Artist: "Queen", Title: "Under Pressure"}},
+		},
+		{
+			name:    "multiple nodes",
+			station: utils.Station{ArtistXPath: "//history/track/@artist", TitleXPath: "//history/track/title"},
+			// The track without an artist is skipped
+			want: []Song{{Artist: "Queen", Title: "Under Pressure"}, {Artist: "Daft Punk", Title: "One More Time"}},
+		},
+		{
+			name: "metadata",
+			station: utils.Station{
+				ArtistXPath:   "//history/track/@artist",
+				TitleXPath:    "//history/track/title",
+				PlayedAtXPath: "//history/track/@played",
+				DurationXPath: "//history/track/length",
+				AlbumXPath:    "//history/track/album",
+				ISRCXPath:     "//history/track/isrc",
+				ExtraXPaths:   map[string]string{"show": "//history/track/show"},
+			},
+			want: []Song{
+				{Artist: "Queen", Title: "Under Pressure", Album: "Hot Space", ISRC: "GBUM71029604", Duration: 4*time.Minute + 8*time.Second, PlayedAt: playedAt(4), Extra: map[string]string{"show": "Morning"}},
+				{Artist: "Daft Punk", Title: "One More Time", Album: "Discovery", Duration: 5*time.Minute + 20*time.Second, PlayedAt: playedAt(0)},
+			},
+		},
+		{
+			name: "metadata of the current song only",
+			// The album matches one node for two songs, so it is dropped
+			station: utils.Station{ArtistXPath: "//history/track/@artist", TitleXPath: "//history/track/title", AlbumXPath: "/radio/history/track[1]/album"},
+			want:    []Song{{Artist: "Queen", Title: "Under Pressure"}, {Artist: "Daft Punk", Title: "One More Time"}},
+		},
+		{
+			name:    "mismatched titles",
+			station: utils.Station{ArtistXPath: "//history/track/@artist", TitleXPath: "/radio/current/title"},
+			wantErr: "title XPath matched 1",
+		},
+		{
+			name:    "no match",
+			station: utils.Station{ArtistXPath: "//song/artist", TitleXPath: "//song/title"},
+			wantErr: "could not find artist or title",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFeedServer(t, "application/xml", testXMLPlaylist)
+			station := tt.station
+			station.ID = "xml_" + strings.ReplaceAll(tt.name, " ", "_")
+			station.Type = "xml"
+			station.URL = server.URL
+
+			s, err := NewXMLScraper(station)
+			if err != nil {
+				t.Fatalf("NewXMLScraper: %v", err)
+			}
+			songs, err := s.GetHistory(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetHistory error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetHistory: %v", err)
+			}
+			if len(songs) != len(tt.want) {
+				t.Fatalf("GetHistory returned %d songs, want %d: %v", len(songs), len(tt.want), songs)
+			}
+			for i, song := range songs {
+				if !reflect.DeepEqual(*song, tt.want[i]) {
+					t.Errorf("song %d = %+v, want %+v", i, *song, tt.want[i])
+				}
+			}
+
+			// The current song is the first one
+			song, err := s.GetNowPlaying(context.Background())
+			if err != nil || song.Artist != tt.want[0].Artist || song.Title != tt.want[0].Title {
+				t.Errorf("GetNowPlaying = %v, %v, want %s - %s", song, err, tt.want[0].Artist, tt.want[0].Title)
+			}
+		})
+	}
+}
+
+func TestNewXMLScraperInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		station utils.Station
+		wantErr string
+	}{
+		{name: "missing title", station: utils.Station{ArtistXPath: "//artist"}, wantErr: "titleXPath"},
+		{name: "invalid artist", station: utils.Station{ArtistXPath: "//artist[", TitleXPath: "//title"}, wantErr: "invalid artist XPath"},
+		{name: "invalid metadata", station: utils.Station{ArtistXPath: "//artist", TitleXPath: "//title", ExtraXPaths: map[string]string{"show": "//show[@"}}, wantErr: "invalid show XPath"},
+	}
+	for _, tt := range tests {
+		tt.station.ID = "test"
+		tt.station.Type = "xml"
+		if _, err := NewXMLScraper(tt.station); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: NewXMLScraper error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
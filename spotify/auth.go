@@ -21,13 +21,13 @@ var (
 )
 
 // initializeAuthenticator initializes the Spotify authenticator
-func initializeAuthenticator() {
-	clientID := utils.GetEnv("SPOTIFY_ID", "")
-	clientSecret := utils.GetEnv("SPOTIFY_SECRET", "")
-	redirectURL := utils.GetEnv("SPOTIFY_REDIRECT_URL", "http://localhost:8080/callback")
+func initializeAuthenticator(config utils.SpotifyConfig) {
+	clientID := config.ID
+	clientSecret := config.Secret
+	redirectURL := config.RedirectURL
 
 	if clientID == "" || clientSecret == "" {
-		fmt.Println("Please set SPOTIFY_ID and SPOTIFY_SECRET environment variables")
+		fmt.Println("Please set spotify.id and spotify.secret in the config file, or the SPOTIFY_ID and SPOTIFY_SECRET environment variables")
 		os.Exit(1)
 	}
 
@@ -45,9 +45,10 @@ func initializeAuthenticator() {
 	)
 }
 
-func getAuthToken() (*oauth2.Token, error) {
+func getAuthToken(config utils.SpotifyConfig) (*oauth2.Token, error) {
+	tokenFile = config.TokenFile
 	defer saveTokenToFile(tokenFile, token) // Save token to file when function exits
-	initializeAuthenticator()               // Initialize authenticator
+	initializeAuthenticator(config)         // Initialize authenticator
 
 	token, err := loadTokenFromFile(tokenFile)
 	if err != nil {
@@ -63,7 +64,7 @@ func getAuthToken() (*oauth2.Token, error) {
 	}
 
 	http.HandleFunc("/callback", completeAuth)
-	go http.ListenAndServe(":"+utils.GetEnv("SPOTIFY_PORT", "8999"), nil)
+	go http.ListenAndServe(fmt.Sprintf(":%d", config.Port), nil)
 
 	url := authenticator.AuthURL("state-token")
 	fmt.Println("Please log in to Spotify by visiting the following page in your browser:", url)
@@ -139,8 +140,8 @@ func loadTokenFromFile(path string) (*oauth2.Token, error) {
 	return &token, nil
 }
 
-func getClient() (*spotify.Client, error) {
-	token, err := getAuthToken()
+func getClient(config utils.SpotifyConfig) (*spotify.Client, error) {
+	token, err := getAuthToken(config)
 	if err != nil {
 		return nil, err
 	}
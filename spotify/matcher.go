@@ -0,0 +1,190 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"radio-to-spotify/scraper"
+	"radio-to-spotify/storage"
+	"radio-to-spotify/utils"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Number of search results scored per query
+const searchLimit = 10
+
+var (
+	// Featured artists in a title, e.g. "Song (feat. Artist)" or "Song ft. Artist"
+	featuredArtists = regexp.MustCompile(`(?i)\s*[(\[]?\b(?:feat\.?|ft\.?|featuring)\s+([^)\]]*)[)\]]?`)
+	// Bracketed parts and " - " suffixes like "(Radio Edit)" or " - Remastered 2011", which are ignored when comparing
+	titleExtras   = regexp.MustCompile(`\([^)]*\)|\[[^\]]*\]|\s-\s.*$`)
+	nonWordChars  = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+	strongMarkers = regexp.MustCompile(`(?i)\b(karaoke|instrumental|cover|tribute|in the style of|made famous|originally performed|backing track)\b`)
+	weakMarkers   = regexp.MustCompile(`(?i)\b(live|remix|sped up|slowed|nightcore|8d|acoustic|reverb)\b`)
+)
+
+// Candidate is a Spotify search result with its score against a song, from 0 to 1
+type Candidate struct {
+	Track spotify.FullTrack
+	Score float64
+}
+
+// SearchCandidates searches Spotify for a song and returns the results by score, best first. The song is searched
+// with artist: and track: filters first, and with a free-text query if that finds nothing above the minimum score.
+func (s *SpotifyService) SearchCandidates(ctx context.Context, song scraper.Song) ([]Candidate, error) {
+	artists, title := songArtists(song)
+	queries := []string{
+		fmt.Sprintf(`track:"%s" artist:"%s"`, stripQuotes(title), stripQuotes(artists[0])),
+		fmt.Sprintf("%s %s", song.Artist, song.Title),
+	}
+
+	var candidates []Candidate
+	seen := make(map[spotify.ID]bool)
+	for _, query := range queries {
+		searchResults, err := s.client.Search(ctx, query, spotify.SearchTypeTrack, spotify.Limit(searchLimit))
+		if err != nil {
+			return nil, err
+		}
+		if searchResults.Tracks != nil {
+			for _, track := range searchResults.Tracks.Tracks {
+				if seen[track.ID] {
+					continue
+				}
+				seen[track.ID] = true
+				candidates = append(candidates, Candidate{Track: track, Score: scoreTrack(song, track)})
+			}
+		}
+
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+		if len(candidates) > 0 && candidates[0].Score >= s.minScore {
+			break
+		}
+	}
+
+	for _, candidate := range candidates {
+		utils.Logger.Debugf("Candidate for %s - %s: %s - %s (%s) scored %.2f", song.Artist, song.Title,
+			joinArtists(candidate.Track.Artists), candidate.Track.Name, candidate.Track.ID, candidate.Score)
+	}
+	return candidates, nil
+}
+
+// scoreTrack scores how well a track matches a song. The title counts most, then the artists and a little the
+// popularity. A different duration, if the station reports one, and markers like "karaoke" or "live" that are not in
+// the song title lower the score.
+func scoreTrack(song scraper.Song, track spotify.FullTrack) float64 {
+	artists, title := songArtists(song)
+
+	titleScore := similarity(comparable(title), comparable(track.Name))
+
+	var trackArtists []string
+	for _, artist := range track.Artists {
+		trackArtists = append(trackArtists, comparable(artist.Name))
+	}
+	// The main artist has to match, featured artists only count as far as they are credited
+	primary := bestSimilarity(comparable(artists[0]), trackArtists)
+	credited := 0
+	for _, artist := range artists {
+		if bestSimilarity(comparable(artist), trackArtists) >= 0.8 {
+			credited++
+		}
+	}
+	artistScore := 0.7*primary + 0.3*float64(credited)/float64(len(artists))
+
+	score := 0.5*titleScore + 0.4*artistScore + 0.1*float64(track.Popularity)/100
+
+	if song.Duration > 0 {
+		diff := song.Duration - track.TimeDuration()
+		if diff < 0 {
+			diff = -diff
+		}
+		// Up to 5 seconds are rounding and fades, a minute off is a different recording
+		score -= 0.3 * math.Min(math.Max(float64(diff-5*time.Second)/float64(55*time.Second), 0), 1)
+	}
+
+	versionText := track.Name + " " + track.Album.Name + " " + joinArtists(track.Artists)
+	if marker := strongMarkers.FindString(versionText); marker != "" && !strings.Contains(strings.ToLower(song.Title), strings.ToLower(marker)) {
+		score -= 0.5
+	}
+	if marker := weakMarkers.FindString(versionText); marker != "" && !strings.Contains(strings.ToLower(song.Title), strings.ToLower(marker)) {
+		score -= 0.2
+	}
+
+	return math.Max(score, 0)
+}
+
+// songArtists returns the artists of a song, including those featured in the title, and the title without them
+func songArtists(song scraper.Song) ([]string, string) {
+	artists := storage.SplitArtists(song.Artist)
+	title := song.Title
+	if match := featuredArtists.FindStringSubmatch(title); match != nil {
+		artists = append(artists, storage.SplitArtists(strings.ReplaceAll(match[1], ",", " & "))...)
+		title = strings.TrimSpace(featuredArtists.ReplaceAllString(title, ""))
+	}
+	return artists, title
+}
+
+// comparable lower cases a name and removes punctuation and the extras of titles
+func comparable(name string) string {
+	name = titleExtras.ReplaceAllString(strings.ToLower(name), "")
+	return strings.TrimSpace(nonWordChars.ReplaceAllString(name, " "))
+}
+
+// similarity returns 1 for equal strings and 0 for completely different ones, based on the edit distance
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func bestSimilarity(name string, names []string) float64 {
+	best := 0.0
+	for _, other := range names {
+		best = math.Max(best, similarity(name, other))
+	}
+	return best
+}
+
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func joinArtists(artists []spotify.SimpleArtist) string {
+	var names []string
+	for _, artist := range artists {
+		names = append(names, artist.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// stripQuotes removes quotes, which would end a field filter of a search query
+func stripQuotes(value string) string {
+	return strings.ReplaceAll(value, `"`, "")
+}
@@ -0,0 +1,116 @@
+package spotify
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"radio-to-spotify/scraper"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func testTrack(name, album string, popularity int, duration time.Duration, artists ...string) spotify.FullTrack {
+	track := spotify.FullTrack{
+		SimpleTrack: spotify.SimpleTrack{Name: name, Duration: spotify.Numeric(duration / time.Millisecond)},
+		Album:       spotify.SimpleAlbum{Name: album},
+		Popularity:  spotify.Numeric(popularity),
+	}
+	for _, artist := range artists {
+		track.Artists = append(track.Artists, spotify.SimpleArtist{Name: artist})
+	}
+	return track
+}
+
+func TestScoreTrack(t *testing.T) {
+	queen := scraper.Song{Artist: "Queen", Title: "Don't Stop Me Now"}
+	timed := scraper.Song{Artist: "Queen", Title: "Don't Stop Me Now", Duration: 3*time.Minute + 29*time.Second}
+	oneKiss := scraper.Song{Artist: "Calvin Harris", Title: "One Kiss (feat. Dua Lipa)"}
+
+	tests := []struct {
+		name  string
+		song  scraper.Song
+		track spotify.FullTrack
+		want  float64
+	}{
+		{name: "exact", song: queen, track: testTrack("Don't Stop Me Now", "Jazz", 100, 0, "Queen"), want: 1},
+		{name: "unpopular", song: queen, track: testTrack("Don't Stop Me Now", "Jazz", 0, 0, "Queen"), want: 0.9},
+		{name: "case, punctuation and suffix", song: queen, track: testTrack("DON'T STOP ME NOW - Remastered 2011", "Jazz", 0, 0, "queen"), want: 0.9},
+		{name: "other artist", song: queen, track: testTrack("Don't Stop Me Now", "Hits", 0, 0, "McFly"), want: 0.5},
+		{name: "featured artist credited", song: oneKiss, track: testTrack("One Kiss (with Dua Lipa)", "One Kiss", 0, 0, "Calvin Harris", "Dua Lipa"), want: 0.9},
+		{name: "featured artist missing", song: oneKiss, track: testTrack("One Kiss", "One Kiss", 0, 0, "Calvin Harris"), want: 0.5 + 0.4*(0.7+0.3/2)},
+		{name: "duration within 5 seconds", song: timed, track: testTrack("Don't Stop Me Now", "Jazz", 0, 3*time.Minute+33*time.Second, "Queen"), want: 0.9},
+		{name: "duration 30 seconds off", song: timed, track: testTrack("Don't Stop Me Now", "Jazz", 0, 3*time.Minute+59*time.Second, "Queen"), want: 0.9 - 0.3*25/55},
+		{name: "duration a minute off", song: timed, track: testTrack("Don't Stop Me Now", "Jazz", 0, 4*time.Minute+29*time.Second, "Queen"), want: 0.6},
+		{name: "karaoke", song: queen, track: testTrack("Don't Stop Me Now", "Karaoke Hits", 0, 0, "Queen"), want: 0.4},
+		{name: "karaoke asked for", song: scraper.Song{Artist: "Queen", Title: "Don't Stop Me Now (Karaoke)"}, track: testTrack("Don't Stop Me Now (Karaoke)", "Karaoke Hits", 0, 0, "Queen"), want: 0.9},
+		{name: "live", song: queen, track: testTrack("Don't Stop Me Now", "Live at Wembley", 0, 0, "Queen"), want: 0.7},
+		{name: "never negative", song: queen, track: testTrack("Bohemian Rhapsody (Live)", "Karaoke", 0, 0, "Tribute Band"), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scoreTrack(tt.song, tt.track); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("scoreTrack = %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreTrackRanking(t *testing.T) {
+	song := scraper.Song{Artist: "Daft Punk", Title: "One More Time", Duration: 5*time.Minute + 20*time.Second}
+	// Best first
+	tracks := []spotify.FullTrack{
+		testTrack("One More Time", "Discovery", 80, 5*time.Minute+20*time.Second, "Daft Punk"),
+		testTrack("One More Time - Remastered", "Discovery", 40, 5*time.Minute+18*time.Second, "Daft Punk"),
+		testTrack("One More Time (Live)", "Alive 2007", 60, 5*time.Minute+20*time.Second, "Daft Punk"),
+		testTrack("One More Time", "Karaoke Classics", 10, 5*time.Minute+20*time.Second, "Karaoke Stars"),
+	}
+
+	previous := math.Inf(1)
+	for _, track := range tracks {
+		score := scoreTrack(song, track)
+		if score >= previous {
+			t.Errorf("%s (%s) scored %.2f, not below the previous track with %.2f", track.Name, track.Album.Name, score, previous)
+		}
+		previous = score
+	}
+}
+
+func TestSongArtists(t *testing.T) {
+	// The artists are split and lower cased like the artists of match keys
+	tests := []struct {
+		song        scraper.Song
+		wantArtists []string
+		wantTitle   string
+	}{
+		{song: scraper.Song{Artist: "Queen", Title: "Under Pressure"}, wantArtists: []string{"queen"}, wantTitle: "Under Pressure"},
+		{song: scraper.Song{Artist: "Queen & David Bowie", Title: "Under Pressure"}, wantArtists: []string{"queen", "david bowie"}, wantTitle: "Under Pressure"},
+		{song: scraper.Song{Artist: "Calvin Harris", Title: "One Kiss (feat. Dua Lipa)"}, wantArtists: []string{"calvin harris", "dua lipa"}, wantTitle: "One Kiss"},
+		{song: scraper.Song{Artist: "DJ", Title: "Song ft. A, B"}, wantArtists: []string{"dj", "a", "b"}, wantTitle: "Song"},
+	}
+	for _, tt := range tests {
+		if artists, title := songArtists(tt.song); !slices.Equal(artists, tt.wantArtists) || title != tt.wantTitle {
+			t.Errorf("songArtists(%q, %q) = %q, %q, want %q, %q", tt.song.Artist, tt.song.Title, artists, title, tt.wantArtists, tt.wantTitle)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"queen", "queen", 1},
+		{"queen", "", 0},
+		{"queen", "queer", 0.8},
+		{"beyonce", "beyoncé", 1 - 1.0/7},
+	}
+	for _, tt := range tests {
+		if got := similarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -3,24 +3,31 @@ package spotify
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"radio-to-spotify/scraper"
 	"radio-to-spotify/storage"
 	"radio-to-spotify/utils"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/zmb3/spotify/v2"
 )
 
+// Spotify IDs are 22 base62 characters
+var trackIDPattern = regexp.MustCompile(`^[0-9A-Za-z]{22}$`)
+
 type SpotifyService struct {
 	client        *spotify.Client
 	configHandler *utils.ConfigHandler
 	store         storage.Storage
 	cache         *storage.SongCache
+	minScore      float64 // Lowest score of a search result that counts as a match
 }
 
-func NewSpotifyService(configHandler *utils.ConfigHandler, store storage.Storage) (*SpotifyService, error) {
+func NewSpotifyService(config utils.SpotifyConfig, configHandler *utils.ConfigHandler, store storage.Storage, cache *storage.SongCache) (*SpotifyService, error) {
 	utils.Logger.Debug("Initializing Spotify service")
-	client, err := getClient()
+	client, err := getClient(config)
 	if err != nil {
 		return nil, err
 	}
@@ -33,18 +40,17 @@ func NewSpotifyService(configHandler *utils.ConfigHandler, store storage.Storage
 	}
 	utils.Logger.Infof("Logged in as: %s", user.DisplayName)
 
-	cache := storage.NewSongCache()
-
 	return &SpotifyService{
 		client:        client,
 		configHandler: configHandler,
 		store:         store,
 		cache:         cache,
+		minScore:      config.MinScore,
 	}, nil
 }
 
-func (s *SpotifyService) UpdateSpotifyPlaylist(stationID, timeRange string) error {
-	_, err := s.client.CurrentUser(context.Background())
+func (s *SpotifyService) UpdateSpotifyPlaylist(ctx context.Context, stationID, timeRange string) error {
+	_, err := s.client.CurrentUser(ctx)
 	if err != nil {
 		return err
 	}
@@ -57,11 +63,11 @@ func (s *SpotifyService) UpdateSpotifyPlaylist(stationID, timeRange string) erro
 	var songs []scraper.Song
 	switch timeRange {
 	case "lasthour":
-		songs, err = s.store.GetSongsSince(stationID, time.Now().Add(-1*time.Hour))
+		songs, err = s.store.GetSongsSince(ctx, stationID, time.Now().Add(-1*time.Hour))
 	case "lastday":
-		songs, err = s.store.GetSongsSince(stationID, time.Now().Add(-24*time.Hour))
+		songs, err = s.store.GetSongsSince(ctx, stationID, time.Now().Add(-24*time.Hour))
 	case "lastweek":
-		songs, err = s.store.GetSongsSince(stationID, time.Now().Add(-7*24*time.Hour))
+		songs, err = s.store.GetSongsSince(ctx, stationID, time.Now().Add(-7*24*time.Hour))
 	default:
 		return fmt.Errorf("invalid time range: %s", timeRange)
 	}
@@ -76,7 +82,7 @@ func (s *SpotifyService) UpdateSpotifyPlaylist(stationID, timeRange string) erro
 
 	playlistID := spotify.ID(station.PlaylistID)
 
-	err = s.ReplaceSongsInPlaylist(playlistID, songs)
+	err = s.ReplaceSongsInPlaylist(ctx, playlistID, songs)
 	if err != nil {
 		return err
 	}
@@ -85,48 +91,39 @@ func (s *SpotifyService) UpdateSpotifyPlaylist(stationID, timeRange string) erro
 	return nil
 }
 
-func (s *SpotifyService) ReplaceSongsInPlaylist(playlistID spotify.ID, songs []scraper.Song) error {
+func (s *SpotifyService) ReplaceSongsInPlaylist(ctx context.Context, playlistID spotify.ID, songs []scraper.Song) error {
 	var trackIDs []spotify.ID
+	sources := make(map[matchSource]int)
+	noMatches := make(map[matchSource]int)
 
 	for _, song := range songs {
-		// Check if the song is already in the cache
-		if cachedID, found := s.cache.GetFromCache(song.Artist, song.Title); found {
-			trackID := spotify.ID(cachedID)
-			trackIDs = append(trackIDs, trackID)
-			utils.Logger.Debugf("Using cached track ID for: %s - %s", song.Artist, song.Title)
-			continue
-		}
-
-		searchResults, err := s.client.Search(context.Background(), fmt.Sprintf("%s %s", song.Artist, song.Title), spotify.SearchTypeTrack)
+		trackID, source, err := s.matchTrack(ctx, song)
 		if err != nil {
-			utils.Logger.Warnf("Error searching for track: %s by %s: %v", song.Title, song.Artist, err)
 			return err
 		}
-		if searchResults.Tracks.Total > 0 && len(searchResults.Tracks.Tracks) > 0 {
-			track := searchResults.Tracks.Tracks[0]
-			utils.Logger.Debugf("Found track: %s - %s", track.Artists[0].Name, track.Name)
-			trackIDs = append(trackIDs, track.ID)
-			s.cache.AddToCache(song.Artist, song.Title, track.ID.String())
-		}
-		if searchResults.Tracks.Total == 0 {
-			utils.Logger.Warnf("No track found for: %s - %s", song.Artist, song.Title)
-		}
-		if len(searchResults.Tracks.Tracks) == 0 {
-			utils.Logger.Warnf("Track Page is empty for: %s - %s (%s)", song.Artist, song.Title, searchResults.Tracks.Endpoint)
+		if trackID != "" {
+			trackIDs = append(trackIDs, trackID)
+			sources[source]++
+		} else {
+			noMatches[source]++
 		}
-
 	}
 
+	utils.Logger.Infof("Matched %d of %d songs (%d overridden, %d cached, %d stored, %d searched), %d without match (%d skipped, %d known, %d new)",
+		len(trackIDs), len(songs), sources[fromOverride], sources[fromCache], sources[fromStore], sources[fromSearch],
+		len(songs)-len(trackIDs), noMatches[fromOverride], noMatches[fromCache]+noMatches[fromStore], noMatches[fromSearch])
+	utils.SetCacheStats(s.cache.Stats())
+
 	utils.Logger.Debugf("Replacing playlist %s with %d tracks", playlistID, len(trackIDs))
 
 	// Replace the entire playlist with the new tracks
 	if len(trackIDs) > 100 {
-		err := s.replacePlaylistTracksInBatches(playlistID, trackIDs)
+		err := s.replacePlaylistTracksInBatches(ctx, playlistID, trackIDs)
 		if err != nil {
 			return err
 		}
 	} else {
-		err := s.client.ReplacePlaylistTracks(context.Background(), playlistID, trackIDs...)
+		err := s.client.ReplacePlaylistTracks(ctx, playlistID, trackIDs...)
 		if err != nil {
 			return err
 		}
@@ -135,9 +132,126 @@ func (s *SpotifyService) ReplaceSongsInPlaylist(playlistID spotify.ID, songs []s
 	return nil
 }
 
-func (s *SpotifyService) replacePlaylistTracksInBatches(playlistID spotify.ID, trackIDs []spotify.ID) error {
+// matchSource is where the match of a song came from
+type matchSource int
+
+const (
+	fromOverride matchSource = iota
+	fromCache
+	fromStore
+	fromSearch
+)
+
+// matchTrack returns the Spotify track of a song from the overrides, the cache, the stored matches or a search, in
+// that order.
+// Searches are stored as matches, including songs without a match. Returns an empty ID if there is no match. Songs
+// without a match are only searched again after the no match expiration, they may have been added to Spotify since.
+func (s *SpotifyService) matchTrack(ctx context.Context, song scraper.Song) (spotify.ID, matchSource, error) {
+	override, err := s.store.GetTrackOverride(ctx, song.Artist, song.Title)
+	if err != nil {
+		utils.Logger.Warnf("Error getting override for: %s - %s: %v", song.Artist, song.Title, err)
+	} else if override != nil {
+		utils.Logger.Debugf("Using override for: %s - %s", song.Artist, song.Title)
+		return spotify.ID(override.SpotifyID), fromOverride, nil
+	}
+
+	if cached, found := s.cache.GetFromCache(song.Artist, song.Title); found {
+		if cached.NoMatch {
+			utils.Logger.Debugf("Skipping cached song without match: %s - %s", song.Artist, song.Title)
+		} else {
+			utils.Logger.Debugf("Using cached track ID for: %s - %s", song.Artist, song.Title)
+		}
+		return spotify.ID(cached.TrackID), fromCache, nil
+	}
+
+	match, err := s.store.GetTrackMatch(ctx, song.Artist, song.Title)
+	if err != nil {
+		utils.Logger.Warnf("Error getting stored match for: %s - %s: %v", song.Artist, song.Title, err)
+	} else if match != nil && !match.NoMatch {
+		utils.Logger.Debugf("Using stored match for: %s - %s", song.Artist, song.Title)
+		s.cache.AddToCache(song.Artist, song.Title, match.SpotifyID)
+		return spotify.ID(match.SpotifyID), fromStore, nil
+	} else if match != nil && s.cache.AddNoMatch(song.Artist, song.Title, match.MatchedAt) {
+		utils.Logger.Debugf("Skipping song without stored match: %s - %s", song.Artist, song.Title)
+		return "", fromStore, nil
+	}
+
+	candidates, err := s.SearchCandidates(ctx, song)
+	if err != nil {
+		utils.Logger.Warnf("Error searching for track: %s by %s: %v", song.Title, song.Artist, err)
+		return "", fromSearch, err
+	}
+
+	match = &storage.TrackMatch{Artist: song.Artist, Title: song.Title}
+	switch {
+	case len(candidates) == 0:
+		utils.Logger.Warnf("No track found for: %s - %s", song.Artist, song.Title)
+		match.NoMatch = true
+	case candidates[0].Score < s.minScore:
+		best := candidates[0]
+		utils.Logger.Warnf("No track scored high enough for: %s - %s, best was %s - %s with %.2f", song.Artist, song.Title,
+			joinArtists(best.Track.Artists), best.Track.Name, best.Score)
+		match.NoMatch = true
+		match.Confidence = best.Score
+	default:
+		best := candidates[0]
+		utils.Logger.Debugf("Found track: %s - %s with %.2f", joinArtists(best.Track.Artists), best.Track.Name, best.Score)
+		match.SpotifyID = best.Track.ID.String()
+		match.Confidence = best.Score
+		s.cache.AddToCache(song.Artist, song.Title, match.SpotifyID)
+	}
+	if match.NoMatch {
+		s.cache.AddNoMatch(song.Artist, song.Title, time.Now())
+	}
+
+	if err := s.store.StoreTrackMatch(ctx, match); err != nil {
+		utils.Logger.Warnf("Error storing match for: %s - %s: %v", song.Artist, song.Title, err)
+	}
+	return spotify.ID(match.SpotifyID), fromSearch, nil
+}
+
+// SearchTrack returns the best scored Spotify search result for a song, or nil if nothing was found. The result
+// may score below the minimum score, see MinScore.
+func (s *SpotifyService) SearchTrack(ctx context.Context, song scraper.Song) (*Candidate, error) {
+	candidates, err := s.SearchCandidates(ctx, song)
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+	return &candidates[0], nil
+}
+
+// MinScore returns the lowest score of a search result that counts as a match
+func (s *SpotifyService) MinScore() float64 {
+	return s.minScore
+}
+
+// GetTrack returns a Spotify track by its ID
+func (s *SpotifyService) GetTrack(ctx context.Context, id spotify.ID) (*spotify.FullTrack, error) {
+	return s.client.GetTrack(ctx, id)
+}
+
+// ParseTrackID returns the track ID of a Spotify track ID, URI (spotify:track:...) or URL (https://open.spotify.com/track/...)
+func ParseTrackID(value string) (spotify.ID, error) {
+	id := value
+	if strings.HasPrefix(value, "spotify:track:") {
+		id = strings.TrimPrefix(value, "spotify:track:")
+	} else if parsed, err := url.Parse(value); err == nil && parsed.Host == "open.spotify.com" {
+		// Localized URLs have a language segment, e.g. /intl-de/track/...
+		parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+		if len(parts) < 2 || parts[len(parts)-2] != "track" {
+			return "", fmt.Errorf("not a Spotify track URL: %s", value)
+		}
+		id = parts[len(parts)-1]
+	}
+	if !trackIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid Spotify track ID: %s", value)
+	}
+	return spotify.ID(id), nil
+}
+
+func (s *SpotifyService) replacePlaylistTracksInBatches(ctx context.Context, playlistID spotify.ID, trackIDs []spotify.ID) error {
 	// Clear the playlist first
-	err := s.client.ReplacePlaylistTracks(context.Background(), playlistID)
+	err := s.client.ReplacePlaylistTracks(ctx, playlistID)
 	if err != nil {
 		return err
 	}
@@ -148,7 +262,7 @@ func (s *SpotifyService) replacePlaylistTracksInBatches(playlistID spotify.ID, t
 			end = len(trackIDs)
 		}
 		batch := trackIDs[i:end]
-		_, err := s.client.AddTracksToPlaylist(context.Background(), playlistID, batch...)
+		_, err := s.client.AddTracksToPlaylist(ctx, playlistID, batch...)
 		if err != nil {
 			utils.Logger.Errorf("Error adding batch of tracks to playlist %s: %v", playlistID, err)
 			return err
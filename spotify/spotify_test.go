@@ -0,0 +1,205 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"radio-to-spotify/scraper"
+	"radio-to-spotify/storage"
+	"radio-to-spotify/utils"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+const testNoMatchExpiration = time.Hour
+
+// fakeAPI answers every search with the same tracks and records the tracks playlists are replaced with
+type fakeAPI struct {
+	mu       sync.Mutex
+	tracks   []spotify.FullTrack
+	searches int
+	playlist []string // Track URIs of the last replaced playlist
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/search":
+		f.searches++
+		var result spotify.SearchResult
+		result.Tracks = &spotify.FullTrackPage{Tracks: f.tracks}
+		json.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/playlists/"):
+		f.playlist = nil
+		if uris := r.URL.Query().Get("uris"); uris != "" {
+			f.playlist = strings.Split(uris, ",")
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"snapshot_id": "test"}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeAPI) searchCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.searches
+}
+
+// newTestService returns a service using a fake Spotify API that finds the given tracks, a SQLite store and an
+// in-memory cache
+func newTestService(t *testing.T, tracks ...spotify.FullTrack) (*SpotifyService, *fakeAPI) {
+	t.Helper()
+	api := &fakeAPI{tracks: tracks}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	store, err := storage.NewSQLiteStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	return &SpotifyService{
+		client:   spotify.New(server.Client(), spotify.WithBaseURL(server.URL+"/")),
+		store:    store,
+		cache:    storage.NewSongCache(utils.CacheConfig{Expiration: time.Hour, NoMatchExpiration: testNoMatchExpiration, MaxSize: 100}),
+		minScore: 0.6,
+	}, api
+}
+
+func underPressure(id string) spotify.FullTrack {
+	track := testTrack("Under Pressure", "Hot Space", 80, 0, "Queen", "David Bowie")
+	track.ID = spotify.ID(id)
+	return track
+}
+
+func TestMatchTrackNoMatchExpiration(t *testing.T) {
+	song := scraper.Song{Artist: "Queen & David Bowie", Title: "Under Pressure"}
+
+	tests := []struct {
+		name         string
+		matchedAt    time.Duration // Time since the song was stored without a match
+		wantID       spotify.ID
+		wantSource   matchSource
+		wantSearches int
+	}{
+		{name: "recent", matchedAt: time.Minute, wantSource: fromStore},
+		// The first query finds a good enough track, so the plain query isn't needed
+		{name: "expired", matchedAt: testNoMatchExpiration + time.Minute, wantID: "found", wantSource: fromSearch, wantSearches: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, api := newTestService(t, underPressure("found"))
+			ctx := context.Background()
+			noMatch := &storage.TrackMatch{Artist: song.Artist, Title: song.Title, NoMatch: true, MatchedAt: time.Now().Add(-tt.matchedAt)}
+			if err := s.store.StoreTrackMatch(ctx, noMatch); err != nil {
+				t.Fatalf("StoreTrackMatch: %v", err)
+			}
+
+			id, source, err := s.matchTrack(ctx, song)
+			if err != nil || id != tt.wantID || source != tt.wantSource {
+				t.Fatalf("matchTrack = %q from %v, %v, want %q from %v", id, source, err, tt.wantID, tt.wantSource)
+			}
+			if searches := api.searchCount(); searches != tt.wantSearches {
+				t.Errorf("searched %d times, want %d", searches, tt.wantSearches)
+			}
+
+			// The result is cached, so the song isn't searched again
+			if again, source, err := s.matchTrack(ctx, song); err != nil || again != id || source != fromCache {
+				t.Errorf("second matchTrack = %q from %v, %v, want %q from the cache", again, source, err, id)
+			}
+			if searches := api.searchCount(); searches != tt.wantSearches {
+				t.Errorf("second matchTrack searched again, %d searches", searches)
+			}
+
+			stored, err := s.store.GetTrackMatch(ctx, song.Artist, song.Title)
+			if err != nil || stored == nil || stored.SpotifyID != string(tt.wantID) || stored.NoMatch != (tt.wantID == "") {
+				t.Errorf("stored match = %+v, %v, want %q", stored, err, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestMatchTrackExpiredCachedNoMatch(t *testing.T) {
+	s, api := newTestService(t, underPressure("found"))
+	ctx := context.Background()
+	song := scraper.Song{Artist: "Queen & David Bowie", Title: "Under Pressure"}
+
+	// Cached without a match almost the no match expiration ago
+	s.cache.AddNoMatch(song.Artist, song.Title, time.Now().Add(100*time.Millisecond-testNoMatchExpiration))
+	if id, source, err := s.matchTrack(ctx, song); err != nil || id != "" || source != fromCache {
+		t.Fatalf("matchTrack = %q from %v, %v, want no match from the cache", id, source, err)
+	}
+	if api.searchCount() != 0 {
+		t.Fatal("matchTrack searched a cached song without match")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if id, source, err := s.matchTrack(ctx, song); err != nil || id != "found" || source != fromSearch {
+		t.Errorf("matchTrack after the expiration = %q from %v, %v, want found from a search", id, source, err)
+	}
+}
+
+func TestMatchTrackOverride(t *testing.T) {
+	s, api := newTestService(t, underPressure("found"))
+	ctx := context.Background()
+	song := scraper.Song{Artist: "Queen & David Bowie", Title: "Under Pressure"}
+
+	// The override wins over the cached and the stored match
+	s.cache.AddToCache(song.Artist, song.Title, "cached")
+	if err := s.store.StoreTrackMatch(ctx, &storage.TrackMatch{Artist: song.Artist, Title: song.Title, SpotifyID: "stored"}); err != nil {
+		t.Fatalf("StoreTrackMatch: %v", err)
+	}
+	// Overrides apply to every spelling with the same match key
+	if err := s.store.StoreTrackOverride(ctx, &storage.TrackOverride{Artist: "David Bowie & Queen", Title: "under pressure", SpotifyID: "override"}); err != nil {
+		t.Fatalf("StoreTrackOverride: %v", err)
+	}
+	if id, source, err := s.matchTrack(ctx, song); err != nil || id != "override" || source != fromOverride {
+		t.Errorf("matchTrack = %q from %v, %v, want the override", id, source, err)
+	}
+
+	// A skip override leaves the song out even though it has a match
+	if err := s.store.StoreTrackOverride(ctx, &storage.TrackOverride{Artist: song.Artist, Title: song.Title, Skip: true}); err != nil {
+		t.Fatalf("StoreTrackOverride: %v", err)
+	}
+	if id, source, err := s.matchTrack(ctx, song); err != nil || id != "" || source != fromOverride {
+		t.Errorf("matchTrack = %q from %v, %v, want the song skipped by the override", id, source, err)
+	}
+	if api.searchCount() != 0 {
+		t.Errorf("overridden song was searched %d times", api.searchCount())
+	}
+}
+
+func TestReplaceSongsInPlaylistSkipOverride(t *testing.T) {
+	s, api := newTestService(t, underPressure("found"))
+	ctx := context.Background()
+	skipped := scraper.Song{Artist: "Queen", Title: "Bohemian Rhapsody"}
+	s.cache.AddToCache(skipped.Artist, skipped.Title, "cached")
+	if err := s.store.StoreTrackOverride(ctx, &storage.TrackOverride{Artist: skipped.Artist, Title: skipped.Title, Skip: true}); err != nil {
+		t.Fatalf("StoreTrackOverride: %v", err)
+	}
+
+	songs := []scraper.Song{skipped, {Artist: "Queen & David Bowie", Title: "Under Pressure"}, skipped}
+	if err := s.ReplaceSongsInPlaylist(ctx, "playlist", songs); err != nil {
+		t.Fatalf("ReplaceSongsInPlaylist: %v", err)
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.playlist) != 1 || api.playlist[0] != "spotify:track:found" {
+		t.Errorf("playlist = %v, want only the searched track", api.playlist)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"radio-to-spotify/utils"
 	"regexp"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -15,18 +14,25 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+// Redis value of songs without a match, track IDs are never empty
+const noMatchValue = "-"
+
 type CacheItem struct {
-	TrackID string
+	TrackID   string
+	NoMatch   bool      // Spotify has no track for the song
+	ExpiresAt time.Time // Expiry of no match items in memory, matches only leave the cache by eviction
 }
 
 type SongCache struct {
-	mu         sync.Mutex
-	cache      map[string]*list.Element // Map from "artist - title" to *list.Element for constant-time lookups
-	orderList  *list.List               // Doubly linked list to maintain LRU order
-	redis      *redis.Client            // Redis client (optional)
-	ctx        context.Context          // Context for Redis operations
-	expiration time.Duration            // Expiration time for cached items
-	maxSize    int                      // Maximum cache size before eviction
+	mu                sync.Mutex
+	cache             map[string]*list.Element // Map from "artist - title" to *list.Element for constant-time lookups
+	orderList         *list.List               // Doubly linked list to maintain LRU order
+	redis             *redis.Client            // Redis client (optional)
+	ctx               context.Context          // Context for Redis operations
+	expiration        time.Duration            // Expiration time for cached items
+	noMatchExpiration time.Duration            // Expiration time for songs without a match
+	maxSize           int                      // Maximum cache size before eviction
+	stats             utils.CacheStats
 }
 
 // List item containing key and value
@@ -36,10 +42,8 @@ type entry struct {
 }
 
 // Initialize the cache with optional Redis, expiration, and size limit
-func NewSongCache() *SongCache {
-	expiration := getExpiration()
-	maxSize := getMaxSize()
-	redisClient := initializeRedis()
+func NewSongCache(config utils.CacheConfig) *SongCache {
+	redisClient := initializeRedis(config.RedisURL)
 
 	if redisClient == nil {
 		utils.Logger.Debug("Redis is not configured, using in-memory cache only")
@@ -48,37 +52,44 @@ func NewSongCache() *SongCache {
 	}
 
 	return &SongCache{
-		cache:      make(map[string]*list.Element),
-		orderList:  list.New(),
-		redis:      redisClient,
-		ctx:        context.Background(),
-		expiration: expiration,
-		maxSize:    maxSize,
+		cache:             make(map[string]*list.Element),
+		orderList:         list.New(),
+		redis:             redisClient,
+		ctx:               context.Background(),
+		expiration:        config.Expiration,
+		noMatchExpiration: config.NoMatchExpiration,
+		maxSize:           config.MaxSize,
 	}
 }
 
-// NormalizeKey normalizes the artist and title to a consistent format
-func normalizeKey(artist, title string) string {
+// MatchKey normalizes the artist and title to a consistent format, used as the key of cached and stored matches
+func MatchKey(artist, title string) string {
 	normalizedArtist := normalizeArtist(artist)
 	normalizedTitle := strings.ToLower(strings.TrimSpace(title))
 	return fmt.Sprintf("%s - %s", normalizedArtist, normalizedTitle)
 }
 
-// NormalizeArtist standardizes the artist name format
-func normalizeArtist(artist string) string {
-	// Define a regex pattern to match separators (e.g., "x", ";", "&", "feat.", "featuring")
-	separators := regexp.MustCompile(`(?i)\s*(x|;|&|feat\.|featuring)\s*`)
-
-	// Replace all recognized separators with a comma
-	normalized := separators.ReplaceAllString(artist, ",")
+// Separators between artist names (e.g., "x", "/", ";", "&", "feat.", "ft.", "featuring"). "x", "/" and the words
+// only count between spaces, so names like "Alex Clare" or "AC/DC" stay intact.
+var artistSeparators = regexp.MustCompile(`(?i)\s+(x|/|feat\.|ft\.|featuring)\s+|\s*(;|&)\s*`)
 
-	// Split the normalized string into individual artist names
-	artists := strings.Split(normalized, ",")
+// SplitArtists splits an artist string into the lower case names of the individual artists
+func SplitArtists(artist string) []string {
+	// Replace all recognized separators with a comma and split into individual artist names
+	artists := strings.Split(artistSeparators.ReplaceAllString(artist, ","), ",")
 
 	// Trim whitespace from each artist name and convert to lowercase
 	for i := range artists {
 		artists[i] = strings.ToLower(strings.TrimSpace(artists[i]))
 	}
+	return artists
+}
+
+// NormalizeArtist standardizes the artist name format. It deliberately doesn't use the configurable normalization of
+// the scraper package: songs are normalized before they are stored, and match keys must not change when the rules of a
+// station do.
+func normalizeArtist(artist string) string {
+	artists := SplitArtists(artist)
 
 	// Sort artist names alphabetically to ensure consistent ordering
 	sort.Strings(artists)
@@ -92,82 +103,144 @@ func (sc *SongCache) AddToCache(artist, title, trackID string) {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 
-	key := normalizeKey(artist, title)
+	sc.set(MatchKey(artist, title), CacheItem{TrackID: trackID})
+	utils.Logger.Debugf("Added song to cache: %s - %s -> %s", artist, title, trackID)
+
+	// Add to Redis with expiration if Redis is configured
+	sc.setRedis(MatchKey(artist, title), trackID, sc.expiration)
+}
+
+// AddNoMatch caches that Spotify has no track for a song. The entry expires after the no match expiration counted
+// from since, so songs are searched again in case they are added to Spotify. Returns false without caching anything
+// if that time has already passed.
+func (sc *SongCache) AddNoMatch(artist, title string, since time.Time) bool {
+	expiresAt := since.Add(sc.noMatchExpiration)
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return false
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
 
+	sc.set(MatchKey(artist, title), CacheItem{NoMatch: true, ExpiresAt: expiresAt})
+	utils.Logger.Debugf("Added song without match to cache for %v: %s - %s", ttl.Round(time.Second), artist, title)
+
+	sc.setRedis(MatchKey(artist, title), noMatchValue, ttl)
+	return true
+}
+
+// set adds or updates an item of the in-memory cache. Must be called with mu held.
+func (sc *SongCache) set(key string, item CacheItem) {
 	// If item exists in cache, move it to the front (most recently used)
 	if element, found := sc.cache[key]; found {
 		sc.orderList.MoveToFront(element)
-		element.Value.(*entry).value.TrackID = trackID
-		utils.Logger.Debugf("Updated song in cache: %s - %s -> %s", artist, title, trackID)
+		element.Value.(*entry).value = item
 		return
 	}
 
 	// Add new item to cache and list
-	newEntry := &entry{
-		key:   key,
-		value: CacheItem{TrackID: trackID},
-	}
-	element := sc.orderList.PushFront(newEntry)
+	element := sc.orderList.PushFront(&entry{key: key, value: item})
 	sc.cache[key] = element
 
-	utils.Logger.Debugf("Added song to in-memory cache: %s - %s -> %s", artist, title, trackID)
-
 	// Evict oldest if cache is full
 	if sc.orderList.Len() > sc.maxSize {
 		sc.evictOldest()
 	}
+}
 
-	// Add to Redis with expiration if Redis is configured
-	if sc.redis != nil {
-		err := sc.redis.Set(sc.ctx, key, trackID, sc.expiration).Err()
-		if err != nil {
-			utils.Logger.Warnf("Failed to add song to Redis: %v", err)
-		} else {
-			utils.Logger.Debugf("Added song to Redis: %s - %s -> %s", artist, title, trackID)
-		}
+func (sc *SongCache) setRedis(key, value string, ttl time.Duration) {
+	if sc.redis == nil {
+		return
+	}
+	err := sc.redis.Set(sc.ctx, key, value, ttl).Err()
+	if err != nil {
+		utils.Logger.Warnf("Failed to add song to Redis: %v", err)
+	} else {
+		utils.Logger.Debugf("Added song to Redis: %s -> %s", key, value)
 	}
 }
 
-// Get a song from the cache
-func (sc *SongCache) GetFromCache(artist, title string) (string, bool) {
+// Get a song from the cache. A found item either has a track ID or is a song without a match.
+func (sc *SongCache) GetFromCache(artist, title string) (CacheItem, bool) {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 
-	key := normalizeKey(artist, title)
+	key := MatchKey(artist, title)
 
 	// Check in-memory cache
 	if element, found := sc.cache[key]; found {
-		// Move accessed item to the front (most recently used)
-		sc.orderList.MoveToFront(element)
-		utils.Logger.Debugf("Got song from in-memory cache: %s - %s -> %s", artist, title, element.Value.(*entry).value.TrackID)
-		return element.Value.(*entry).value.TrackID, true
+		item := element.Value.(*entry).value
+		if item.NoMatch && time.Now().After(item.ExpiresAt) {
+			delete(sc.cache, key)
+			sc.orderList.Remove(element)
+		} else {
+			// Move accessed item to the front (most recently used)
+			sc.orderList.MoveToFront(element)
+			sc.countHit(item)
+			utils.Logger.Debugf("Got song from in-memory cache: %s - %s -> %s", artist, title, item.TrackID)
+			return item, true
+		}
 	}
 
 	// Check Redis if not found in-memory
 	if sc.redis != nil {
-		trackID, err := sc.redis.Get(sc.ctx, key).Result()
+		value, err := sc.redis.Get(sc.ctx, key).Result()
 		if err == redis.Nil {
 			utils.Logger.Debugf("Song not found in Redis cache: %s - %s", artist, title)
-			return "", false
+			sc.stats.Misses++
+			return CacheItem{}, false
 		} else if err != nil {
 			utils.Logger.Warnf("Failed to retrieve song from Redis: %v", err)
-			return "", false
+			sc.stats.Misses++
+			return CacheItem{}, false
 		}
 
-		// Add Redis entry to in-memory cache
-		newEntry := &entry{
-			key:   key,
-			value: CacheItem{TrackID: trackID},
+		// Songs without a match stay in Redis only, which expires them
+		if value == noMatchValue {
+			item := CacheItem{NoMatch: true}
+			sc.countHit(item)
+			utils.Logger.Debugf("Got song without match from Redis: %s - %s", artist, title)
+			return item, true
 		}
-		element := sc.orderList.PushFront(newEntry)
-		sc.cache[key] = element
 
-		utils.Logger.Debugf("Got song from Redis and added to in-memory cache: %s - %s -> %s", artist, title, trackID)
-		return trackID, true
+		// Add Redis entry to in-memory cache
+		item := CacheItem{TrackID: value}
+		sc.set(key, item)
+		sc.countHit(item)
+		utils.Logger.Debugf("Got song from Redis and added to in-memory cache: %s - %s -> %s", artist, title, value)
+		return item, true
 	}
 
 	utils.Logger.Debugf("Song not found in any cache: %s - %s", artist, title)
-	return "", false
+	sc.stats.Misses++
+	return CacheItem{}, false
+}
+
+// countHit counts a cache hit. Must be called with mu held.
+func (sc *SongCache) countHit(item CacheItem) {
+	if item.NoMatch {
+		sc.stats.NoMatchHits++
+	} else {
+		sc.stats.Hits++
+	}
+}
+
+// Stats returns the number of in-memory entries and the hits and misses since the cache was created
+func (sc *SongCache) Stats() utils.CacheStats {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	stats := sc.stats
+	stats.Matches, stats.NoMatches = 0, 0
+	for element := sc.orderList.Front(); element != nil; element = element.Next() {
+		if element.Value.(*entry).value.NoMatch {
+			stats.NoMatches++
+		} else {
+			stats.Matches++
+		}
+	}
+	return stats
 }
 
 // Evict the oldest entry from the in-memory cache
@@ -181,31 +254,8 @@ func (sc *SongCache) evictOldest() {
 	}
 }
 
-// Get expiration duration from environment variable or default value
-func getExpiration() time.Duration {
-	expirationStr := utils.GetEnv("CACHE_EXPIRATION", "756h") // 4 weeks
-	expiration, err := time.ParseDuration(expirationStr)
-	if err != nil {
-		utils.Logger.Warnf("Invalid CACHE_EXPIRATION value: %v. Using default 4w.", err)
-		return 756 * time.Hour
-	}
-	return expiration
-}
-
-// Get max size for in-memory cache from environment variable or default value
-func getMaxSize() int {
-	maxSizeStr := utils.GetEnv("CACHE_MAX_SIZE", "10000")
-	maxSize, err := strconv.Atoi(maxSizeStr)
-	if err != nil {
-		utils.Logger.Warnf("Invalid CACHE_MAX_SIZE value: %v. Using default 1000.", err)
-		return 1000
-	}
-	return maxSize
-}
-
-// Initialize Redis client via environment variables, with support for redis:// connection strings
-func initializeRedis() *redis.Client {
-	redisURL := utils.GetEnv("REDIS_URL", "")
+// Initialize Redis client from a redis:// connection string
+func initializeRedis(redisURL string) *redis.Client {
 	if redisURL == "" {
 		return nil // No Redis URL provided, return nil to indicate no Redis configuration
 	}
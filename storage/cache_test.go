@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"radio-to-spotify/utils"
+)
+
+func TestMatchKey(t *testing.T) {
+	tests := []struct {
+		artist, title string
+		want          string
+	}{
+		{"Queen", "Under Pressure", "queen - under pressure"},
+		{" Queen ", " Under Pressure ", "queen - under pressure"},
+		// Artists are sorted, so the order and the separator don't matter
+		{"Queen & David Bowie", "Under Pressure", "david bowie,queen - under pressure"},
+		{"David Bowie; Queen", "Under Pressure", "david bowie,queen - under pressure"},
+		{"Queen / David Bowie", "Under Pressure", "david bowie,queen - under pressure"},
+		{"Queen feat. David Bowie", "Under Pressure", "david bowie,queen - under pressure"},
+		{"Queen FT. David Bowie", "Under Pressure", "david bowie,queen - under pressure"},
+		{"Queen featuring David Bowie", "Under Pressure", "david bowie,queen - under pressure"},
+		{"Queen x David Bowie", "Under Pressure", "david bowie,queen - under pressure"},
+		// x and / only separate artists between spaces
+		{"Alex Clare", "Too Close", "alex clare - too close"},
+		{"AC/DC", "Thunderstruck", "ac/dc - thunderstruck"},
+		{"Xavier Naidoo", "Dieser Weg", "xavier naidoo - dieser weg"},
+	}
+	for _, tt := range tests {
+		if got := MatchKey(tt.artist, tt.title); got != tt.want {
+			t.Errorf("MatchKey(%q, %q) = %q, want %q", tt.artist, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestSongCacheNoMatchExpiration(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      utils.CacheConfig
+		wantExpired bool
+	}{
+		{
+			name:        "shorter than the expiration",
+			config:      utils.CacheConfig{Expiration: time.Hour, NoMatchExpiration: time.Minute, MaxSize: 10},
+			wantExpired: true,
+		},
+		{
+			name:   "longer than the expiration",
+			config: utils.CacheConfig{Expiration: time.Millisecond, NoMatchExpiration: time.Hour, MaxSize: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewSongCache(tt.config)
+			// The no match expiration counts from since, so a song found without a match a minute ago expires soon
+			// with a no match expiration of a minute
+			since := time.Now().Add(100*time.Millisecond - time.Minute)
+			if !cache.AddNoMatch("Queen", "Unreleased", since) {
+				t.Fatal("AddNoMatch didn't cache a song without match")
+			}
+			cache.AddToCache("Queen", "Under Pressure", "track")
+
+			item, found := cache.GetFromCache("Queen", "Unreleased")
+			if !found || !item.NoMatch || !item.ExpiresAt.Equal(since.Add(tt.config.NoMatchExpiration)) {
+				t.Fatalf("GetFromCache = %+v, %v, want no match expiring at %v", item, found, since.Add(tt.config.NoMatchExpiration))
+			}
+
+			time.Sleep(200 * time.Millisecond)
+			if _, found := cache.GetFromCache("Queen", "Unreleased"); found == tt.wantExpired {
+				t.Errorf("no match found %v after %v, want expired %v", found, time.Since(since).Round(time.Millisecond), tt.wantExpired)
+			}
+			// Matches only leave the in-memory cache by eviction
+			if item, found := cache.GetFromCache("Queen", "Under Pressure"); !found || item.TrackID != "track" {
+				t.Errorf("match = %+v, %v, want the cached track", item, found)
+			}
+		})
+	}
+}
+
+func TestSongCacheExpiredNoMatch(t *testing.T) {
+	cache := NewSongCache(utils.CacheConfig{Expiration: time.Hour, NoMatchExpiration: time.Hour, MaxSize: 10})
+
+	// A song whose no match expiration has passed is not cached, so it is searched again
+	if cache.AddNoMatch("Queen", "Unreleased", time.Now().Add(-time.Hour)) {
+		t.Error("AddNoMatch cached an expired song without match")
+	}
+	if item, found := cache.GetFromCache("Queen", "Unreleased"); found {
+		t.Errorf("GetFromCache = %+v, want not found", item)
+	}
+
+	if !cache.AddNoMatch("Queen", "Unreleased", time.Now()) {
+		t.Fatal("AddNoMatch didn't cache a new song without match")
+	}
+	cache.GetFromCache("Queen", "Unreleased")
+	want := utils.CacheStats{NoMatches: 1, NoMatchHits: 1, Misses: 1}
+	if stats := cache.Stats(); stats != want {
+		t.Errorf("Stats = %+v, want %+v", stats, want)
+	}
+}
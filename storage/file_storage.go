@@ -1,14 +1,17 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"radio-to-spotify/scraper"
+	"radio-to-spotify/utils"
 )
 
 type FileStorage struct {
@@ -17,7 +20,9 @@ type FileStorage struct {
 		scraper.Song
 		Timestamp time.Time `json:"timestamp"`
 	}
-	filePath string
+	matches   map[string]*TrackMatch    // Spotify matches by MatchKey, kept in matches.json
+	overrides map[string]*TrackOverride // Manual overrides by MatchKey, kept in overrides.json
+	filePath  string
 }
 
 func NewFileStorage(filePath string) (*FileStorage, error) {
@@ -31,7 +36,9 @@ func NewFileStorage(filePath string) (*FileStorage, error) {
 			scraper.Song
 			Timestamp time.Time `json:"timestamp"`
 		}),
-		filePath: filePath,
+		matches:   make(map[string]*TrackMatch),
+		overrides: make(map[string]*TrackOverride),
+		filePath:  filePath,
 	}
 	err := fs.loadFromFile()
 	if err != nil {
@@ -40,11 +47,22 @@ func NewFileStorage(filePath string) (*FileStorage, error) {
 	return fs, nil
 }
 
-func (s *FileStorage) Init() error {
-	return s.loadFromFile()
+func (s *FileStorage) Init(ctx context.Context) error {
+	if err := s.loadFromFile(); err != nil {
+		return err
+	}
+	if err := s.loadJSON("matches.json", &s.matches); err != nil {
+		return err
+	}
+	return s.loadJSON("overrides.json", &s.overrides)
+}
+
+// Close does nothing, the songs are written to the file whenever they change
+func (s *FileStorage) Close() error {
+	return nil
 }
 
-func (s *FileStorage) StoreNowPlaying(stationID string, song *scraper.Song) (bool, error) {
+func (s *FileStorage) StoreNowPlaying(ctx context.Context, stationID string, song *scraper.Song) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -56,13 +74,17 @@ func (s *FileStorage) StoreNowPlaying(stationID string, song *scraper.Song) (boo
 		}
 	}
 
-	// Append the new song to the list with timestamp
+	// Append the new song to the list with timestamp, preferring the play time reported by the station
+	timestamp := time.Now()
+	if song.PlayedAt != nil {
+		timestamp = *song.PlayedAt
+	}
 	songWithTimestamp := struct {
 		scraper.Song
 		Timestamp time.Time `json:"timestamp"`
 	}{
 		*song,
-		time.Now(),
+		timestamp,
 	}
 
 	s.songs[stationID] = append(s.songs[stationID], songWithTimestamp)
@@ -72,38 +94,14 @@ func (s *FileStorage) StoreNowPlaying(stationID string, song *scraper.Song) (boo
 }
 
 func (s *FileStorage) loadFromFile() error {
-	dbFile := filepath.Join(s.filePath, "songs.json")
-	file, err := os.Open(dbFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // File does not exist, will create when storing
-		}
-		return err
-	}
-	defer file.Close()
-
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&s.songs)
-	if err != nil {
-		return err
-	}
-	return nil
+	return s.loadJSON("songs.json", &s.songs)
 }
 
 func (s *FileStorage) saveToFile() error {
-	dbFile := filepath.Join(s.filePath, "songs.json")
-	file, err := os.Create(dbFile)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(s.songs)
+	return s.saveJSON("songs.json", s.songs)
 }
 
-func (s *FileStorage) GetNowPlaying(stationID string) (*scraper.Song, error) {
+func (s *FileStorage) GetNowPlaying(ctx context.Context, stationID string) (*scraper.Song, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -115,7 +113,7 @@ func (s *FileStorage) GetNowPlaying(stationID string) (*scraper.Song, error) {
 	return &lastSongs[len(lastSongs)-1].Song, nil
 }
 
-func (s *FileStorage) GetSongsSince(stationID string, sinceTime time.Time) ([]scraper.Song, error) {
+func (s *FileStorage) GetSongsSince(ctx context.Context, stationID string, sinceTime time.Time) ([]scraper.Song, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -134,7 +132,7 @@ func (s *FileStorage) GetSongsSince(stationID string, sinceTime time.Time) ([]sc
 	return songs, nil
 }
 
-func (s *FileStorage) GetAllStations() ([]string, error) {
+func (s *FileStorage) GetAllStations(ctx context.Context) ([]string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -144,3 +142,141 @@ func (s *FileStorage) GetAllStations() ([]string, error) {
 	}
 	return stations, nil
 }
+
+func (s *FileStorage) GetPlays(ctx context.Context, filter PlayFilter) ([]Play, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stationIDs := filter.StationIDs
+	if len(stationIDs) == 0 {
+		for stationID := range s.songs {
+			stationIDs = append(stationIDs, stationID)
+		}
+		sort.Strings(stationIDs)
+	}
+
+	var plays []Play
+	for _, stationID := range stationIDs {
+		for _, song := range s.songs[stationID] {
+			if !filter.From.IsZero() && song.Timestamp.Before(filter.From) {
+				continue
+			}
+			if !filter.To.IsZero() && !song.Timestamp.Before(filter.To) {
+				continue
+			}
+			play := Play{StationID: stationID, Song: song.Song}
+			playedAt := song.Timestamp
+			play.PlayedAt = &playedAt
+			plays = append(plays, play)
+		}
+	}
+
+	// Songs are appended in the order they are stored, the stable sort keeps that order for equal timestamps
+	sort.SliceStable(plays, func(i, j int) bool {
+		return plays[i].PlayedAt.Before(*plays[j].PlayedAt)
+	})
+	if filter.Descending {
+		for i, j := 0, len(plays)-1; i < j; i, j = i+1, j-1 {
+			plays[i], plays[j] = plays[j], plays[i]
+		}
+	}
+
+	if filter.Offset >= len(plays) {
+		return nil, nil
+	}
+	plays = plays[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(plays) {
+		plays = plays[:filter.Limit]
+	}
+	return plays, nil
+}
+
+func (s *FileStorage) GetTrackMatch(ctx context.Context, artist, title string) (*TrackMatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	match, exists := s.matches[MatchKey(artist, title)]
+	if !exists {
+		return nil, nil
+	}
+	copied := *match
+	return &copied, nil
+}
+
+func (s *FileStorage) StoreTrackMatch(ctx context.Context, match *TrackMatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	match.Key = MatchKey(match.Artist, match.Title)
+	if match.MatchedAt.IsZero() {
+		match.MatchedAt = time.Now()
+	}
+	stored := *match
+	s.matches[match.Key] = &stored
+	return s.saveJSON("matches.json", s.matches)
+}
+
+func (s *FileStorage) GetTrackOverride(ctx context.Context, artist, title string) (*TrackOverride, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	override, exists := s.overrides[MatchKey(artist, title)]
+	if !exists {
+		return nil, nil
+	}
+	copied := *override
+	return &copied, nil
+}
+
+func (s *FileStorage) StoreTrackOverride(ctx context.Context, override *TrackOverride) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	override.Key = MatchKey(override.Artist, override.Title)
+	if override.CreatedAt.IsZero() {
+		override.CreatedAt = time.Now()
+	}
+	stored := *override
+	s.overrides[override.Key] = &stored
+	return s.saveJSON("overrides.json", s.overrides)
+}
+
+func (s *FileStorage) DeleteTrackOverride(ctx context.Context, artist, title string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := MatchKey(artist, title)
+	if _, exists := s.overrides[key]; !exists {
+		return false, nil
+	}
+	delete(s.overrides, key)
+	return true, s.saveJSON("overrides.json", s.overrides)
+}
+
+// loadJSON decodes a file of the storage directory into v, leaving v unchanged if the file doesn't exist yet
+func (s *FileStorage) loadJSON(name string, v interface{}) error {
+	file, err := os.Open(filepath.Join(s.filePath, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // File does not exist, will create when storing
+		}
+		return err
+	}
+	defer file.Close()
+
+	return json.NewDecoder(file).Decode(v)
+}
+
+// saveJSON encodes v into a file of the storage directory. The file is replaced atomically, so a crash while saving
+// keeps the previous version instead of leaving it truncated.
+func (s *FileStorage) saveJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(s.filePath, name), append(data, '\n'))
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStorageSavesSongs(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	storePlays(t, store)
+
+	// The files are replaced atomically, no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "songs.json" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("storage directory contains %v, want only songs.json", names)
+	}
+	if err := os.Chmod(filepath.Join(dir, "songs.json"), 0600); err != nil {
+		t.Fatalf("Chmod: %v", err)
+	}
+
+	// Saving again keeps the permissions of the file
+	reopened, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if err := reopened.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	plays, err := reopened.GetPlays(context.Background(), PlayFilter{})
+	if err != nil || playTitles(plays) != "123456" {
+		t.Fatalf("reopened plays = %q, %v, want %q", playTitles(plays), err, "123456")
+	}
+	storePlays(t, reopened)
+	info, err := os.Stat(filepath.Join(dir, "songs.json"))
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("songs.json mode after saving = %v, want 0600", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"radio-to-spotify/utils"
+)
+
+// dialect holds what differs between the SQL backends
+type dialect struct {
+	name string
+	// Statements of the schema migrations by version
+	schema map[int][]string
+	// Query listing the tables of older versions, which stored the songs of each station in a station_<id> table
+	legacyTables string
+	// Query listing the column names of a table
+	tableColumns string
+	// Numbered placeholders ($1, $2, ...) instead of ?
+	numberedPlaceholders bool
+	// LIMIT value for all rows, needed before an OFFSET
+	noLimit string
+	// timeValue converts a time to a query parameter
+	timeValue func(t time.Time) interface{}
+}
+
+// rebind replaces the ? placeholders of a query with the placeholders of the dialect
+func (d *dialect) rebind(query string) string {
+	if !d.numberedPlaceholders {
+		return query
+	}
+	var builder strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// migration is one step of the SQL schema. Migrations run in order of their version, each in a transaction, and are
+// recorded in schema_migrations so each one only runs once.
+type migration struct {
+	version     int
+	description string
+	up          func(ctx context.Context, tx *sql.Tx, d *dialect) error
+}
+
+var migrations = []migration{
+	{1, "create the stations, tracks and plays tables", schemaMigration(1)},
+	{2, "import the station_<id> tables of older versions", importLegacyTables},
+	{3, "create the track_matches table", schemaMigration(3)},
+	{4, "create the track_overrides table", schemaMigration(4)},
+	{5, "add the raw artist and title to plays", schemaMigration(5)},
+}
+
+// migrate applies all migrations that have not been applied yet
+func migrate(ctx context.Context, db *sql.DB, d *dialect) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations: %v", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return err
+		}
+		applied[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := applyMigration(ctx, db, d, m); err != nil {
+			return fmt.Errorf("error applying migration %d (%s): %v", m.version, m.description, err)
+		}
+		utils.Logger.Infof("Applied %s migration %d: %s", d.name, m.version, m.description)
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, d *dialect, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op after a commit
+
+	if err := m.up(ctx, tx, d); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, d.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), m.version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// schemaMigration returns a migration that runs the schema statements of a version
+func schemaMigration(version int) func(ctx context.Context, tx *sql.Tx, d *dialect) error {
+	return func(ctx context.Context, tx *sql.Tx, d *dialect) error {
+		for _, statement := range d.schema[version] {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// importLegacyTables copies the songs of the station_<id> tables into the stations, tracks and plays tables. The old
+// tables are left in place and can be dropped once the import has been checked.
+func importLegacyTables(ctx context.Context, tx *sql.Tx, d *dialect) error {
+	tables, err := queryStrings(ctx, tx, d.legacyTables)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		stationID := strings.TrimPrefix(table, "station_")
+		columns, err := queryStrings(ctx, tx, d.rebind(d.tableColumns), table)
+		if err != nil {
+			return err
+		}
+		imported, err := importLegacyTable(ctx, tx, d, table, stationID, columns)
+		if err != nil {
+			return fmt.Errorf("error importing %s: %v", table, err)
+		}
+		utils.Logger.Infof("Imported %d songs of station %s from %s, the table is no longer used", imported, stationID, table)
+	}
+	return nil
+}
+
+func importLegacyTable(ctx context.Context, tx *sql.Tx, d *dialect, table, stationID string, columns []string) (int64, error) {
+	existing := make(map[string]bool)
+	for _, column := range columns {
+		existing[column] = true
+	}
+	// Tables of older versions may lack the song metadata columns
+	column := func(name, expression string) string {
+		if existing[name] {
+			return expression
+		}
+		return "NULL"
+	}
+
+	// Table names come from the database catalog and are quoted, never taken from input
+	quoted := quoteIdentifier(table)
+
+	if _, err := tx.ExecContext(ctx, d.rebind(`INSERT INTO stations (id) VALUES (?) ON CONFLICT (id) DO NOTHING`), stationID); err != nil {
+		return 0, err
+	}
+
+	// Tracks are shared by all stations, metadata that is missing in one table is taken from another
+	_, err := tx.ExecContext(ctx, `INSERT INTO tracks (artist, title, album, isrc, duration)
+		SELECT artist, title, `+column("album", "MAX(album)")+`, `+column("isrc", "MAX(isrc)")+`, `+column("duration", "MAX(duration)")+`
+		FROM `+quoted+`
+		WHERE artist IS NOT NULL AND title IS NOT NULL
+		GROUP BY artist, title
+		ON CONFLICT (artist, title) DO UPDATE SET
+			album = COALESCE(tracks.album, excluded.album),
+			isrc = COALESCE(tracks.isrc, excluded.isrc),
+			duration = COALESCE(tracks.duration, excluded.duration)`)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, d.rebind(`INSERT INTO plays (station_id, track_id, played_at, extra)
+		SELECT ?, t.id, COALESCE(s.timestamp, CURRENT_TIMESTAMP), `+column("extra", "s.extra")+`
+		FROM `+quoted+` s
+		JOIN tracks t ON t.artist = s.artist AND t.title = s.title
+		ORDER BY s.id`), stationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func queryStrings(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"radio-to-spotify/scraper"
+)
+
+// newTestSQLite opens and migrates a SQLite storage in a temporary directory
+func newTestSQLite(t *testing.T, dir string) *SQLiteStorage {
+	t.Helper()
+	store, err := NewSQLiteStorage(dir)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return store
+}
+
+func appliedMigrations(t *testing.T, db *sql.DB) []int {
+	t.Helper()
+	rows, err := db.Query(`SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		t.Fatalf("query schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			t.Fatalf("scan schema_migrations: %v", err)
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	store := newTestSQLite(t, dir)
+
+	var want []int
+	for _, m := range migrations {
+		want = append(want, m.version)
+	}
+	if got := appliedMigrations(t, store.db); !reflect.DeepEqual(got, want) {
+		t.Fatalf("applied migrations %v, want %v", got, want)
+	}
+
+	// Migrating again is a no-op
+	if err := migrate(context.Background(), store.db, store.dialect); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+	if got := appliedMigrations(t, store.db); !reflect.DeepEqual(got, want) {
+		t.Fatalf("applied migrations after second migrate %v, want %v", got, want)
+	}
+}
+
+func TestImportLegacyTables(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(dir, "db.sqlite"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	// station_a has the columns of the first versions, station_b the song metadata columns added later
+	statements := []string{
+		`CREATE TABLE station_a (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			artist TEXT,
+			title TEXT,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`INSERT INTO station_a (artist, title, timestamp) VALUES
+			('Queen', 'Under Pressure', '2024-05-01 10:00:00'),
+			(NULL, 'Jingle', '2024-05-01 10:04:00'),
+			('Daft Punk', 'One More Time', '2024-05-01 10:05:00')`,
+		`CREATE TABLE station_b (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			artist TEXT,
+			title TEXT,
+			album TEXT,
+			isrc TEXT,
+			duration INTEGER,
+			extra TEXT,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`INSERT INTO station_b (artist, title, album, isrc, duration, extra, timestamp) VALUES
+			('Queen', 'Under Pressure', 'Hot Space', 'GBUM71029604', 248, '{"show":"Morning"}', '2024-05-01 11:00:00')`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("create legacy table: %v", err)
+		}
+	}
+	db.Close()
+
+	store := newTestSQLite(t, dir)
+	ctx := context.Background()
+
+	stations, err := store.GetAllStations(ctx)
+	if err != nil {
+		t.Fatalf("GetAllStations: %v", err)
+	}
+	if !reflect.DeepEqual(stations, []string{"a", "b"}) {
+		t.Errorf("stations = %v, want [a b]", stations)
+	}
+
+	plays, err := store.GetPlays(ctx, PlayFilter{})
+	if err != nil {
+		t.Fatalf("GetPlays: %v", err)
+	}
+	// The play without an artist is skipped, the track of both stations is stored once with the metadata of station_b
+	underPressure := scraper.Song{Artist: "Queen", Title: "Under Pressure", Album: "Hot Space", ISRC: "GBUM71029604", Duration: 248 * time.Second}
+	want := []struct {
+		stationID string
+		song      scraper.Song
+		playedAt  time.Time
+	}{
+		{"a", underPressure, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{"a", scraper.Song{Artist: "Daft Punk", Title: "One More Time"}, time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC)},
+		{"b", underPressure, time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)},
+	}
+	want[2].song.Extra = map[string]string{"show": "Morning"}
+
+	if len(plays) != len(want) {
+		t.Fatalf("imported %d plays, want %d: %v", len(plays), len(want), plays)
+	}
+	for i, play := range plays {
+		playedAt := *play.PlayedAt
+		play.PlayedAt = nil
+		if play.StationID != want[i].stationID || !reflect.DeepEqual(play.Song, want[i].song) || !playedAt.Equal(want[i].playedAt) {
+			t.Errorf("play %d = %s %+v at %v, want %s %+v at %v", i, play.StationID, play.Song, playedAt,
+				want[i].stationID, want[i].song, want[i].playedAt)
+		}
+	}
+
+	var tracks int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM tracks`).Scan(&tracks); err != nil {
+		t.Fatalf("count tracks: %v", err)
+	}
+	if tracks != 2 {
+		t.Errorf("imported %d tracks, want 2", tracks)
+	}
+
+	// The legacy tables are kept, and reopening the storage doesn't import them again
+	store.Close()
+	store = newTestSQLite(t, dir)
+	if plays, err := store.GetPlays(ctx, PlayFilter{}); err != nil || len(plays) != len(want) {
+		t.Errorf("after reopening got %d plays (%v), want %d", len(plays), err, len(want))
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := `SELECT * FROM plays WHERE station_id IN (?, ?) AND played_at >= ?`
+	if got := sqliteDialect.rebind(query); got != query {
+		t.Errorf("sqlite rebind = %q, want the query unchanged", got)
+	}
+	want := `SELECT * FROM plays WHERE station_id IN ($1, $2) AND played_at >= $3`
+	if got := postgresDialect.rebind(query); got != want {
+		t.Errorf("postgres rebind = %q, want %q", got, want)
+	}
+}
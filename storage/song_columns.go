@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"radio-to-spotify/scraper"
+)
+
+// playColumns are the columns of a play joined with its track, as read by scanSong
+const playColumns = "t.artist, t.title, t.album, t.isrc, t.duration, p.extra, p.raw_artist, p.raw_title, p.played_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// songValues returns the artist, title, album, isrc and duration of a track followed by the extra metadata and the raw
+// artist and title of the play. Optional fields are stored as NULL when empty.
+func songValues(song *scraper.Song) ([]interface{}, error) {
+	var album, isrc, duration, extra, rawArtist, rawTitle interface{}
+	if song.Album != "" {
+		album = song.Album
+	}
+	if song.ISRC != "" {
+		isrc = song.ISRC
+	}
+	if song.Duration > 0 {
+		duration = int64(song.Duration.Round(time.Second) / time.Second)
+	}
+	if len(song.Extra) > 0 {
+		data, err := json.Marshal(song.Extra)
+		if err != nil {
+			return nil, err
+		}
+		extra = string(data)
+	}
+	if song.RawArtist != "" {
+		rawArtist = song.RawArtist
+	}
+	if song.RawTitle != "" {
+		rawTitle = song.RawTitle
+	}
+	return []interface{}{song.Artist, song.Title, album, isrc, duration, extra, rawArtist, rawTitle}, nil
+}
+
+// scanSong reads playColumns followed by more columns into the given destinations
+func scanSong(row rowScanner, more ...interface{}) (*scraper.Song, error) {
+	var song scraper.Song
+	var album, isrc, extra, rawArtist, rawTitle sql.NullString
+	var duration sql.NullInt64
+	var timestamp sql.NullTime
+	err := row.Scan(append([]interface{}{&song.Artist, &song.Title, &album, &isrc, &duration, &extra, &rawArtist, &rawTitle, &timestamp}, more...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	song.Album = album.String
+	song.ISRC = isrc.String
+	song.Duration = time.Duration(duration.Int64) * time.Second
+	song.RawArtist = rawArtist.String
+	song.RawTitle = rawTitle.String
+	if extra.Valid && extra.String != "" {
+		if err := json.Unmarshal([]byte(extra.String), &song.Extra); err != nil {
+			return nil, err
+		}
+	}
+	if timestamp.Valid {
+		song.PlayedAt = &timestamp.Time
+	}
+	return &song, nil
+}
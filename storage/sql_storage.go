@@ -0,0 +1,348 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"radio-to-spotify/scraper"
+)
+
+// sqlStorage stores songs in the stations, tracks and plays tables. It is shared by the SQLite and PostgreSQL
+// backends, which only differ in their dialect.
+type sqlStorage struct {
+	mu      sync.Mutex
+	songs   map[string]*scraper.Song // Last stored song of each station
+	db      *sql.DB
+	dialect *dialect
+}
+
+func newSQLStorage(db *sql.DB, d *dialect) *sqlStorage {
+	return &sqlStorage{
+		songs:   make(map[string]*scraper.Song),
+		db:      db,
+		dialect: d,
+	}
+}
+
+// Init migrates the schema and loads the last song of each station
+func (s *sqlStorage) Init(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := migrate(ctx, s.db, s.dialect); err != nil {
+		return err
+	}
+
+	stations, err := s.getAllStations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, stationID := range stations {
+		song, err := s.loadLastSong(ctx, stationID)
+		if err == nil {
+			s.songs[stationID] = song
+		}
+	}
+	return nil
+}
+
+// Close closes the database, waiting for running queries to finish
+func (s *sqlStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqlStorage) loadLastSong(ctx context.Context, stationID string) (*scraper.Song, error) {
+	row := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+playColumns+`
+		FROM plays p JOIN tracks t ON t.id = p.track_id
+		WHERE p.station_id = ?
+		ORDER BY p.played_at DESC, p.id DESC
+		LIMIT 1`), stationID)
+	song, err := scanSong(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("no song found for station")
+		}
+		return nil, err
+	}
+	return song, nil
+}
+
+func (s *sqlStorage) StoreNowPlaying(ctx context.Context, stationID string, song *scraper.Song) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lastSong, exists := s.songs[stationID]
+	if exists && lastSong.Artist == song.Artist && lastSong.Title == song.Title {
+		return false, nil // Song hasn't changed
+	}
+
+	values, err := songValues(song)
+	if err != nil {
+		return false, err
+	}
+
+	// Use the play time reported by the station if available
+	var playedAt interface{}
+	if song.PlayedAt != nil {
+		playedAt = s.dialect.timeValue(*song.PlayedAt)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback() // No-op after a commit
+
+	_, err = tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO stations (id) VALUES (?) ON CONFLICT (id) DO NOTHING`), stationID)
+	if err != nil {
+		return false, err
+	}
+
+	// Tracks are shared by all stations, metadata that is missing in one station is kept from another
+	var trackID int64
+	err = tx.QueryRowContext(ctx, s.dialect.rebind(`INSERT INTO tracks (artist, title, album, isrc, duration) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (artist, title) DO UPDATE SET
+			album = COALESCE(excluded.album, tracks.album),
+			isrc = COALESCE(excluded.isrc, tracks.isrc),
+			duration = COALESCE(excluded.duration, tracks.duration)
+		RETURNING id`), values[:5]...).Scan(&trackID)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO plays (station_id, track_id, played_at, extra, raw_artist, raw_title)
+		VALUES (?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?, ?, ?)`), stationID, trackID, playedAt, values[5], values[6], values[7])
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	s.songs[stationID] = song
+	return true, nil
+}
+
+func (s *sqlStorage) GetNowPlaying(ctx context.Context, stationID string) (*scraper.Song, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	song, exists := s.songs[stationID]
+	if !exists {
+		return nil, errors.New("no song found for station")
+	}
+
+	return song, nil
+}
+
+func (s *sqlStorage) GetSongsSince(ctx context.Context, stationID string, sinceTime time.Time) ([]scraper.Song, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.songs[stationID]; !exists {
+		return nil, errors.New("no song found for station")
+	}
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT `+playColumns+`
+		FROM plays p JOIN tracks t ON t.id = p.track_id
+		WHERE p.station_id = ? AND p.played_at > ?
+		ORDER BY p.played_at, p.id`), stationID, s.dialect.timeValue(sinceTime))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var songs []scraper.Song
+	for rows.Next() {
+		song, err := scanSong(rows)
+		if err != nil {
+			return nil, err
+		}
+		songs = append(songs, *song)
+	}
+
+	return songs, rows.Err()
+}
+
+func (s *sqlStorage) GetAllStations(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.getAllStations(ctx)
+}
+
+func (s *sqlStorage) getAllStations(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM stations ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stations []string
+	for rows.Next() {
+		var stationID string
+		if err := rows.Scan(&stationID); err != nil {
+			return nil, err
+		}
+		stations = append(stations, stationID)
+	}
+
+	return stations, rows.Err()
+}
+
+func (s *sqlStorage) GetPlays(ctx context.Context, filter PlayFilter) ([]Play, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+
+	var conditions []string
+	var args []interface{}
+	if len(filter.StationIDs) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.StationIDs)), ", ")
+		conditions = append(conditions, "p.station_id IN ("+placeholders+")")
+		for _, stationID := range filter.StationIDs {
+			args = append(args, stationID)
+		}
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "p.played_at >= ?")
+		args = append(args, s.dialect.timeValue(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "p.played_at < ?")
+		args = append(args, s.dialect.timeValue(filter.To))
+	}
+
+	query := `SELECT ` + playColumns + `, p.station_id FROM plays p JOIN tracks t ON t.id = p.track_id`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if filter.Descending {
+		query += " ORDER BY p.played_at DESC, p.id DESC"
+	} else {
+		query += " ORDER BY p.played_at, p.id"
+	}
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	} else if filter.Offset > 0 {
+		query += " LIMIT " + s.dialect.noLimit
+	}
+	if filter.Offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, filter.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plays []Play
+	for rows.Next() {
+		var play Play
+		song, err := scanSong(rows, &play.StationID)
+		if err != nil {
+			return nil, err
+		}
+		play.Song = *song
+		plays = append(plays, play)
+	}
+
+	return plays, rows.Err()
+}
+
+func (s *sqlStorage) GetTrackMatch(ctx context.Context, artist, title string) (*TrackMatch, error) {
+	var match TrackMatch
+	var spotifyID sql.NullString
+	var confidence sql.NullFloat64
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT match_key, artist, title, spotify_id, confidence, no_match, matched_at
+		FROM track_matches WHERE match_key = ?`), MatchKey(artist, title)).
+		Scan(&match.Key, &match.Artist, &match.Title, &spotifyID, &confidence, &match.NoMatch, &match.MatchedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	match.SpotifyID = spotifyID.String
+	match.Confidence = confidence.Float64
+	return &match, nil
+}
+
+func (s *sqlStorage) StoreTrackMatch(ctx context.Context, match *TrackMatch) error {
+	match.Key = MatchKey(match.Artist, match.Title)
+	if match.MatchedAt.IsZero() {
+		match.MatchedAt = time.Now()
+	}
+
+	var spotifyID, confidence interface{}
+	if match.SpotifyID != "" {
+		spotifyID = match.SpotifyID
+	}
+	if match.Confidence > 0 {
+		confidence = match.Confidence
+	}
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO track_matches (match_key, artist, title, spotify_id, confidence, no_match, matched_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (match_key) DO UPDATE SET
+			artist = excluded.artist,
+			title = excluded.title,
+			spotify_id = excluded.spotify_id,
+			confidence = excluded.confidence,
+			no_match = excluded.no_match,
+			matched_at = excluded.matched_at`),
+		match.Key, match.Artist, match.Title, spotifyID, confidence, match.NoMatch, s.dialect.timeValue(match.MatchedAt))
+	return err
+}
+
+func (s *sqlStorage) GetTrackOverride(ctx context.Context, artist, title string) (*TrackOverride, error) {
+	var override TrackOverride
+	var spotifyID sql.NullString
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT match_key, artist, title, spotify_id, skip, created_at
+		FROM track_overrides WHERE match_key = ?`), MatchKey(artist, title)).
+		Scan(&override.Key, &override.Artist, &override.Title, &spotifyID, &override.Skip, &override.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	override.SpotifyID = spotifyID.String
+	return &override, nil
+}
+
+func (s *sqlStorage) StoreTrackOverride(ctx context.Context, override *TrackOverride) error {
+	override.Key = MatchKey(override.Artist, override.Title)
+	if override.CreatedAt.IsZero() {
+		override.CreatedAt = time.Now()
+	}
+
+	var spotifyID interface{}
+	if override.SpotifyID != "" {
+		spotifyID = override.SpotifyID
+	}
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO track_overrides (match_key, artist, title, spotify_id, skip, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (match_key) DO UPDATE SET
+			artist = excluded.artist,
+			title = excluded.title,
+			spotify_id = excluded.spotify_id,
+			skip = excluded.skip,
+			created_at = excluded.created_at`),
+		override.Key, override.Artist, override.Title, spotifyID, override.Skip, s.dialect.timeValue(override.CreatedAt))
+	return err
+}
+
+func (s *sqlStorage) DeleteTrackOverride(ctx context.Context, artist, title string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM track_overrides WHERE match_key = ?`), MatchKey(artist, title))
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
@@ -2,25 +2,81 @@ package storage
 
 import (
 	"database/sql"
-	"errors"
-	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
-	"sync"
 	"time"
 
-	"radio-to-spotify/scraper"
-
 	_ "github.com/ncruces/go-sqlite3"
 	_ "github.com/ncruces/go-sqlite3/driver"
 	_ "github.com/ncruces/go-sqlite3/embed"
 )
 
+var sqliteDialect = &dialect{
+	name: "sqlite",
+	schema: map[int][]string{
+		1: {
+			`CREATE TABLE stations (
+				id TEXT PRIMARY KEY,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE tracks (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				artist TEXT NOT NULL,
+				title TEXT NOT NULL,
+				album TEXT,
+				isrc TEXT,
+				duration INTEGER,
+				UNIQUE (artist, title)
+			)`,
+			`CREATE TABLE plays (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				station_id TEXT NOT NULL REFERENCES stations (id),
+				track_id INTEGER NOT NULL REFERENCES tracks (id),
+				played_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				extra TEXT
+			)`,
+			`CREATE INDEX plays_station_played_at ON plays (station_id, played_at)`,
+			`CREATE INDEX plays_track ON plays (track_id)`,
+			`CREATE INDEX tracks_isrc ON tracks (isrc)`,
+		},
+		3: {
+			`CREATE TABLE track_matches (
+				match_key TEXT PRIMARY KEY,
+				artist TEXT NOT NULL,
+				title TEXT NOT NULL,
+				spotify_id TEXT,
+				confidence REAL,
+				no_match INTEGER NOT NULL DEFAULT 0,
+				matched_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX track_matches_spotify_id ON track_matches (spotify_id)`,
+		},
+		4: {
+			`CREATE TABLE track_overrides (
+				match_key TEXT PRIMARY KEY,
+				artist TEXT NOT NULL,
+				title TEXT NOT NULL,
+				spotify_id TEXT,
+				skip INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+		5: {
+			`ALTER TABLE plays ADD COLUMN raw_artist TEXT`,
+			`ALTER TABLE plays ADD COLUMN raw_title TEXT`,
+		},
+	},
+	legacyTables: `SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE 'station\_%' ESCAPE '\' ORDER BY name`,
+	tableColumns: `SELECT name FROM pragma_table_info(?)`,
+	noLimit:      "-1",
+	// Timestamps are stored as text in the format of CURRENT_TIMESTAMP, which is UTC
+	timeValue: func(t time.Time) interface{} {
+		return t.UTC().Format("2006-01-02 15:04:05")
+	},
+}
+
 type SQLiteStorage struct {
-	mu    sync.Mutex
-	songs map[string]*scraper.Song
-	db    *sql.DB
+	*sqlStorage
 }
 
 func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
@@ -32,155 +88,11 @@ func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
 	// Join the directory path with the database file name
 	dbFile := filepath.Join(dbPath, "db.sqlite")
 
-	// Open the database using the ncruces/go-sqlite3 driver
-	db, err := sql.Open("sqlite3", dbFile)
-	if err != nil {
-		return nil, err
-	}
-	storage := &SQLiteStorage{
-		songs: make(map[string]*scraper.Song),
-		db:    db,
-	}
-
-	return storage, nil
-}
-
-func (s *SQLiteStorage) Init() error {
-	tables, err := s.getStationTables()
-	if err != nil {
-		return err
-	}
-
-	for _, table := range tables {
-		stationID := strings.TrimPrefix(table, "station_")
-		song, err := s.loadLastSong(stationID)
-		if err == nil {
-			s.songs[stationID] = song
-		}
-	}
-	return nil
-}
-
-func (s *SQLiteStorage) getStationTables() ([]string, error) {
-	var tables []string
-	rows, err := s.db.Query(`SELECT name FROM sqlite_master WHERE type='table' AND name LIKE 'station_%'`)
+	// Open the database using the ncruces/go-sqlite3 driver, SQLite only enforces foreign keys if asked to
+	db, err := sql.Open("sqlite3", "file:"+dbFile+"?_pragma=foreign_keys(1)")
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
-			return nil, err
-		}
-		tables = append(tables, tableName)
-	}
-
-	return tables, rows.Err()
-}
-
-func (s *SQLiteStorage) loadLastSong(stationID string) (*scraper.Song, error) {
-	row := s.db.QueryRow(fmt.Sprintf(`SELECT artist, title FROM station_%s ORDER BY timestamp DESC LIMIT 1`, stationID))
-	var song scraper.Song
-	err := row.Scan(&song.Artist, &song.Title)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("no song found for station")
-		}
-		return nil, err
-	}
-	return &song, nil
-}
-
-func (s *SQLiteStorage) createStationTable(stationID string) error {
-	_, err := s.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS station_%s (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		artist TEXT,
-		title TEXT,
-		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
-	)`, stationID))
-	return err
-}
-
-func (s *SQLiteStorage) StoreNowPlaying(stationID string, song *scraper.Song) (bool, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	lastSong, exists := s.songs[stationID]
-	if exists && lastSong.Artist == song.Artist && lastSong.Title == song.Title {
-		return false, nil // Song hasn't changed
-	}
-
-	s.songs[stationID] = song
-
-	// Ensure the table for the station exists
-	err := s.createStationTable(stationID)
-	if err != nil {
-		return false, err
-	}
-
-	// Insert the new song into the station-specific table
-	_, err = s.db.Exec(fmt.Sprintf(`INSERT INTO station_%s (artist, title) VALUES (?, ?)`, stationID),
-		song.Artist, song.Title)
-	if err != nil {
-		return false, err
-	}
-
-	return true, nil
-}
-
-func (s *SQLiteStorage) GetNowPlaying(stationID string) (*scraper.Song, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	song, exists := s.songs[stationID]
-	if !exists {
-		return nil, errors.New("no song found for station")
-	}
-
-	return song, nil
-}
-
-func (s *SQLiteStorage) GetSongsSince(stationID string, sinceTime time.Time) ([]scraper.Song, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if _, exists := s.songs[stationID]; !exists {
-		return nil, errors.New("no song found for station")
-	}
-	rows, err := s.db.Query(fmt.Sprintf(`SELECT artist, title FROM station_%s WHERE timestamp > ?`, stationID), sinceTime.Format("2006-01-02 15:04:05"))
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var songs []scraper.Song
-	for rows.Next() {
-		var song scraper.Song
-		if err := rows.Scan(&song.Artist, &song.Title); err != nil {
-			return nil, err
-		}
-		songs = append(songs, song)
-	}
-
-	return songs, rows.Err()
-}
-
-func (s *SQLiteStorage) GetAllStations() ([]string, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	tables, err := s.getStationTables()
-	if err != nil {
-		return nil, err
-	}
-
-	var stations []string
-	for _, table := range tables {
-		stationID := strings.TrimPrefix(table, "station_")
-		stations = append(stations, stationID)
-	}
 
-	return stations, nil
+	return &SQLiteStorage{newSQLStorage(db, sqliteDialect)}, nil
 }
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -8,11 +10,105 @@ import (
 )
 
 type Storage interface {
-	StoreNowPlaying(stationID string, song *scraper.Song) (bool, error)
-	GetNowPlaying(stationID string) (*scraper.Song, error)
-	GetSongsSince(stationID string, sinceTime time.Time) ([]scraper.Song, error)
-	GetAllStations() ([]string, error)
-	Init() error
+	StoreNowPlaying(ctx context.Context, stationID string, song *scraper.Song) (bool, error)
+	GetNowPlaying(ctx context.Context, stationID string) (*scraper.Song, error)
+	GetSongsSince(ctx context.Context, stationID string, sinceTime time.Time) ([]scraper.Song, error)
+	GetPlays(ctx context.Context, filter PlayFilter) ([]Play, error)
+	GetAllStations(ctx context.Context) ([]string, error)
+	// GetTrackMatch returns the stored match of a song by its MatchKey, or nil if the song wasn't matched yet
+	GetTrackMatch(ctx context.Context, artist, title string) (*TrackMatch, error)
+	// StoreTrackMatch stores a match, replacing the previous match of the song
+	StoreTrackMatch(ctx context.Context, match *TrackMatch) error
+	// GetTrackOverride returns the manual override of a song by its MatchKey, or nil if there is none
+	GetTrackOverride(ctx context.Context, artist, title string) (*TrackOverride, error)
+	// StoreTrackOverride stores an override, replacing the previous override of the song
+	StoreTrackOverride(ctx context.Context, override *TrackOverride) error
+	// DeleteTrackOverride removes the override of a song, returns false if there was none
+	DeleteTrackOverride(ctx context.Context, artist, title string) (bool, error)
+	Init(ctx context.Context) error
+	// Close releases the storage, it must not be used afterwards
+	Close() error
+}
+
+// PlayFilter selects the plays returned by GetPlays. Zero values don't filter.
+type PlayFilter struct {
+	StationIDs []string  // Plays of all stations if empty
+	From       time.Time // Plays at or after this time
+	To         time.Time // Plays before this time
+	Limit      int       // Maximum number of plays, all if 0
+	Offset     int       // Number of plays to skip, for paging through the results
+	Descending bool      // Newest plays first instead of oldest
+}
+
+func (f PlayFilter) validate() error {
+	if f.Limit < 0 || f.Offset < 0 {
+		return errors.New("limit and offset must not be negative")
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return fmt.Errorf("from %v must be before to %v", f.From, f.To)
+	}
+	return nil
+}
+
+// Play is a song played on a station. Song.PlayedAt is always set.
+type Play struct {
+	StationID string
+	scraper.Song
+}
+
+// TrackMatch is the Spotify track a song was matched to, or a record that no track was found
+type TrackMatch struct {
+	Artist     string
+	Title      string
+	Key        string  // MatchKey of the artist and title, set when the match is stored
+	SpotifyID  string  // Empty if there is no match
+	Confidence float64 // Score of the match between 0 and 1, 0 if it wasn't scored
+	MatchedAt  time.Time
+	NoMatch    bool // No track was found for the song
+}
+
+// TrackOverride is a manually chosen Spotify track for a song, or a choice to leave the song out of playlists. It is
+// used instead of the matches and the cache.
+type TrackOverride struct {
+	Artist    string
+	Title     string
+	Key       string // MatchKey of the artist and title, set when the override is stored
+	SpotifyID string // Empty if the song is skipped
+	Skip      bool   // The song is left out of playlists
+	CreatedAt time.Time
+}
+
+// StoreHistory stores the songs of a station history (newest first) that were played since the last stored song,
+// oldest first, so songs that started and ended between two fetches are not lost. If nothing was stored for the
+// station yet, only the current song is stored. Returns the number of stored songs.
+func StoreHistory(ctx context.Context, store Storage, stationID string, history []*scraper.Song) (int, error) {
+	if len(history) == 0 {
+		return 0, nil
+	}
+
+	missed := history[:1]
+	if lastSong, err := store.GetNowPlaying(ctx, stationID); err == nil && lastSong != nil {
+		missed = history
+		for i, song := range history {
+			if song.Artist == lastSong.Artist && song.Title == lastSong.Title {
+				missed = history[:i]
+				break
+			}
+		}
+	}
+
+	var storedCount int
+	for i := len(missed) - 1; i >= 0; i-- {
+		changed, err := store.StoreNowPlaying(ctx, stationID, missed[i])
+		if err != nil {
+			return storedCount, err
+		}
+		if changed {
+			storedCount++
+		}
+	}
+
+	return storedCount, nil
 }
 
 func NewStorage(storageType, storagePath string) (Storage, error) {
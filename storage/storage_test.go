@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"radio-to-spotify/scraper"
+)
+
+var playsStart = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// storePlays stores songs titled 1 to 6, alternating between stations a and b a minute apart. Songs 3 and 4 are
+// played at the same time, so their order depends on the order they were stored in.
+func storePlays(t *testing.T, store Storage) {
+	t.Helper()
+	minutes := []int{0, 1, 2, 2, 4, 5}
+	for i, minute := range minutes {
+		stationID := "a"
+		if i%2 == 1 {
+			stationID = "b"
+		}
+		playedAt := playsStart.Add(time.Duration(minute) * time.Minute)
+		song := &scraper.Song{Artist: "Artist", Title: string(rune('1' + i)), PlayedAt: &playedAt}
+		if _, err := store.StoreNowPlaying(context.Background(), stationID, song); err != nil {
+			t.Fatalf("StoreNowPlaying: %v", err)
+		}
+	}
+}
+
+func playTitles(plays []Play) string {
+	var titles string
+	for _, play := range plays {
+		titles += play.Title
+	}
+	return titles
+}
+
+// testBackends opens the storages that run without a server
+var testBackends = map[string]func(t *testing.T) Storage{
+	"sqlite": func(t *testing.T) Storage {
+		return newTestSQLite(t, t.TempDir())
+	},
+	"file": func(t *testing.T) Storage {
+		store, err := NewFileStorage(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewFileStorage: %v", err)
+		}
+		return store
+	},
+}
+
+func TestGetPlays(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter PlayFilter
+		want   string
+	}{
+		{name: "all", want: "123456"},
+		{name: "descending", filter: PlayFilter{Descending: true}, want: "654321"},
+		{name: "limit", filter: PlayFilter{Limit: 2}, want: "12"},
+		{name: "limit and offset", filter: PlayFilter{Limit: 2, Offset: 2}, want: "34"},
+		{name: "offset only", filter: PlayFilter{Offset: 4}, want: "56"},
+		{name: "offset past the end", filter: PlayFilter{Offset: 10}, want: ""},
+		{name: "newest first", filter: PlayFilter{Descending: true, Limit: 3}, want: "654"},
+		{name: "descending page", filter: PlayFilter{Descending: true, Limit: 2, Offset: 1}, want: "54"},
+		{name: "station", filter: PlayFilter{StationIDs: []string{"b"}}, want: "246"},
+		{name: "stations page", filter: PlayFilter{StationIDs: []string{"a", "b"}, Limit: 1, Offset: 5}, want: "6"},
+		{name: "time range", filter: PlayFilter{From: playsStart.Add(time.Minute), To: playsStart.Add(4 * time.Minute)}, want: "234"},
+		{name: "time range page", filter: PlayFilter{From: playsStart.Add(time.Minute), Limit: 2, Offset: 1}, want: "34"},
+	}
+
+	for name, newStore := range testBackends {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			storePlays(t, store)
+			ctx := context.Background()
+
+			for _, tt := range tests {
+				plays, err := store.GetPlays(ctx, tt.filter)
+				if err != nil {
+					t.Fatalf("%s: GetPlays: %v", tt.name, err)
+				}
+				if got := playTitles(plays); got != tt.want {
+					t.Errorf("%s: GetPlays = %q, want %q", tt.name, got, tt.want)
+				}
+			}
+
+			// Paging through all plays returns each one once
+			var paged []Play
+			for offset := 0; ; offset += 4 {
+				page, err := store.GetPlays(ctx, PlayFilter{Limit: 4, Offset: offset})
+				if err != nil {
+					t.Fatalf("GetPlays at offset %d: %v", offset, err)
+				}
+				if len(page) == 0 {
+					break
+				}
+				paged = append(paged, page...)
+			}
+			if got := playTitles(paged); got != "123456" {
+				t.Errorf("paged plays = %q, want %q", got, "123456")
+			}
+
+			plays, err := store.GetPlays(ctx, PlayFilter{StationIDs: []string{"a"}, Limit: 1})
+			if err != nil || len(plays) != 1 {
+				t.Fatalf("GetPlays of station a = %v, %v", plays, err)
+			}
+			if plays[0].StationID != "a" || plays[0].PlayedAt == nil || !plays[0].PlayedAt.Equal(playsStart) {
+				t.Errorf("first play = %s at %v, want a at %v", plays[0].StationID, plays[0].PlayedAt, playsStart)
+			}
+		})
+	}
+}
+
+func TestPlayFilterValidate(t *testing.T) {
+	tests := []struct {
+		filter  PlayFilter
+		wantErr bool
+	}{
+		{filter: PlayFilter{}},
+		{filter: PlayFilter{Limit: 10, Offset: 20}},
+		{filter: PlayFilter{Limit: -1}, wantErr: true},
+		{filter: PlayFilter{Offset: -1}, wantErr: true},
+		{filter: PlayFilter{From: playsStart, To: playsStart.Add(time.Hour)}},
+		{filter: PlayFilter{From: playsStart, To: playsStart}, wantErr: true},
+		{filter: PlayFilter{From: playsStart.Add(time.Hour), To: playsStart}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.filter.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate(%+v) = %v, want error %v", tt.filter, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPlayTimeZones(t *testing.T) {
+	berlin := time.FixedZone("CEST", 2*60*60)
+	newYork := time.FixedZone("EDT", -4*60*60)
+	// 10:00 UTC, as a station in Berlin would report it
+	playedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, berlin)
+
+	for name, newStore := range testBackends {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			if _, err := store.StoreNowPlaying(ctx, "a", &scraper.Song{Artist: "Artist", Title: "1", PlayedAt: &playedAt}); err != nil {
+				t.Fatalf("StoreNowPlaying: %v", err)
+			}
+			// Stored with the current time
+			if _, err := store.StoreNowPlaying(ctx, "a", &scraper.Song{Artist: "Artist", Title: "2"}); err != nil {
+				t.Fatalf("StoreNowPlaying: %v", err)
+			}
+
+			plays, err := store.GetPlays(ctx, PlayFilter{Limit: 1})
+			if err != nil || len(plays) != 1 {
+				t.Fatalf("GetPlays = %v, %v", plays, err)
+			}
+			if !plays[0].PlayedAt.Equal(playedAt) {
+				t.Errorf("played at %v, want %v", plays[0].PlayedAt, playedAt)
+			}
+
+			// Ranges given in another zone
+			ranges := []struct {
+				filter PlayFilter
+				want   string
+			}{
+				{filter: PlayFilter{From: playedAt.In(newYork), To: playedAt.Add(time.Second).In(newYork)}, want: "1"},
+				{filter: PlayFilter{From: playedAt.Add(-time.Second).In(newYork), To: playedAt.In(newYork)}, want: ""},
+				{filter: PlayFilter{From: playedAt.Add(time.Second).In(berlin), To: time.Now().Add(time.Minute).In(newYork)}, want: "2"},
+				{filter: PlayFilter{From: time.Now().Add(-time.Minute).In(berlin)}, want: "2"},
+			}
+			for _, r := range ranges {
+				plays, err := store.GetPlays(ctx, r.filter)
+				if err != nil {
+					t.Fatalf("GetPlays(%v - %v): %v", r.filter.From, r.filter.To, err)
+				}
+				if got := playTitles(plays); got != r.want {
+					t.Errorf("GetPlays(%v - %v) = %q, want %q", r.filter.From, r.filter.To, got, r.want)
+				}
+			}
+
+			songs, err := store.GetSongsSince(ctx, "a", playedAt.Add(-time.Second).In(newYork))
+			if err != nil || len(songs) != 2 {
+				t.Errorf("GetSongsSince just before the first play = %v, %v, want both songs", songs, err)
+			}
+			songs, err = store.GetSongsSince(ctx, "a", playedAt.In(newYork))
+			if err != nil || len(songs) != 1 || songs[0].Title != "2" {
+				t.Errorf("GetSongsSince the first play = %v, %v, want the second song", songs, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// AppConfig holds all settings of the application. Each setting is read, in order of precedence, from a command line
+// flag, an environment variable, the config file or its default. See Settings for the keys and variables.
+type AppConfig struct {
+	Storage     string `mapstructure:"storage"`      // file, sqlite or postgres
+	StoragePath string `mapstructure:"storage_path"` // Path to the storage file or database connection string
+	LogLevel    string `mapstructure:"log_level"`
+	StationFile string `mapstructure:"station_file"` // Stations file or directory
+	StationID   string `mapstructure:"station_id"`   // Only work on this station if set
+	// Time range of the songs in the playlists: lasthour, lastday or lastweek
+	PlaylistRange string `mapstructure:"playlist_range"`
+
+	Cache       CacheConfig       `mapstructure:"cache"`
+	Spotify     SpotifyConfig     `mapstructure:"spotify"`
+	Daemon      DaemonConfig      `mapstructure:"daemon"`
+	HealthCheck HealthCheckConfig `mapstructure:"healthcheck"`
+	Normalize   NormalizeRules    `mapstructure:"normalize"` // Global normalization rules of scraped songs
+}
+
+type CacheConfig struct {
+	Expiration        time.Duration `mapstructure:"expiration"`
+	NoMatchExpiration time.Duration `mapstructure:"no_match_expiration"` // Expiration of songs Spotify has no track for
+	MaxSize           int           `mapstructure:"max_size"`            // Maximum number of songs in the in-memory cache
+	RedisURL          string        `mapstructure:"redis_url"`           // redis:// connection string, in-memory only if empty
+}
+
+type SpotifyConfig struct {
+	ID          string  `mapstructure:"id"`
+	Secret      string  `mapstructure:"secret"`
+	RedirectURL string  `mapstructure:"redirect_url"`
+	Port        int     `mapstructure:"port"`       // Port of the login callback server
+	TokenFile   string  `mapstructure:"token_file"` // File the login token is kept in
+	MinScore    float64 `mapstructure:"min_score"`  // Lowest score from 0 to 1 of a search result that counts as a match
+}
+
+type DaemonConfig struct {
+	FetchInterval          time.Duration `mapstructure:"fetch_interval"`
+	PlaylistUpdateInterval time.Duration `mapstructure:"playlist_update_interval"`
+	NoStore                bool          `mapstructure:"no_store"`
+	NoPlaylist             bool          `mapstructure:"no_playlist"`
+	Adaptive               bool          `mapstructure:"adaptive"`
+	AdaptiveMinInterval    time.Duration `mapstructure:"adaptive_min_interval"`
+	AdaptiveMaxInterval    time.Duration `mapstructure:"adaptive_max_interval"`
+}
+
+type HealthCheckConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	Port    int  `mapstructure:"port"`
+}
+
+// Setting describes a key of the AppConfig
+type Setting struct {
+	Key        string
+	Default    interface{}
+	LegacyEnvs []string // Environment variables used before the RTS_ prefix, still read after RTS_<KEY>
+	Secret     bool     // Redacted when the config is shown
+}
+
+// Settings lists every key of the AppConfig with its default
+var Settings = []Setting{
+	{Key: "storage", Default: "sqlite"},
+	{Key: "storage_path", Default: "data"},
+	{Key: "log_level", Default: "info"},
+	{Key: "station_file", Default: "stations.json"},
+	{Key: "station_id", Default: ""},
+	{Key: "playlist_range", Default: "lastday"},
+	{Key: "cache.expiration", Default: 756 * time.Hour, LegacyEnvs: []string{"CACHE_EXPIRATION"}}, // 4 weeks
+	{Key: "cache.no_match_expiration", Default: 24 * time.Hour},
+	{Key: "cache.max_size", Default: 10000, LegacyEnvs: []string{"CACHE_MAX_SIZE"}},
+	{Key: "cache.redis_url", Default: "", LegacyEnvs: []string{"REDIS_URL"}, Secret: true},
+	{Key: "spotify.id", Default: "", LegacyEnvs: []string{"SPOTIFY_ID"}},
+	{Key: "spotify.secret", Default: "", LegacyEnvs: []string{"SPOTIFY_SECRET"}, Secret: true},
+	{Key: "spotify.redirect_url", Default: "http://localhost:8080/callback", LegacyEnvs: []string{"SPOTIFY_REDIRECT_URL"}},
+	{Key: "spotify.port", Default: 8999, LegacyEnvs: []string{"SPOTIFY_PORT"}},
+	{Key: "spotify.token_file", Default: "./data/.token"},
+	{Key: "spotify.min_score", Default: 0.6},
+	{Key: "daemon.fetch_interval", Default: time.Minute},
+	{Key: "daemon.playlist_update_interval", Default: time.Hour},
+	{Key: "daemon.no_store", Default: false},
+	{Key: "daemon.no_playlist", Default: false},
+	{Key: "daemon.adaptive", Default: false},
+	{Key: "daemon.adaptive_min_interval", Default: 15 * time.Second},
+	{Key: "daemon.adaptive_max_interval", Default: 10 * time.Minute},
+	{Key: "healthcheck.enabled", Default: false, LegacyEnvs: []string{"ENABLE_HEALTHCHECK"}},
+	{Key: "healthcheck.port", Default: 8585, LegacyEnvs: []string{"HEALTHCHECK_PORT"}},
+	{Key: "normalize.decode_entities", Default: false},
+	{Key: "normalize.collapse_whitespace", Default: false},
+	{Key: "normalize.fix_case", Default: false},
+	{Key: "normalize.extract_featured", Default: false},
+	{Key: "normalize.artist_separators", Default: []string{}},
+	{Key: "normalize.strip_suffixes", Default: []string{}},
+	{Key: "normalize.replace", Default: []ReplaceRule{}},
+}
+
+// Env returns the environment variables of the setting in order of precedence
+func (s Setting) Env() []string {
+	return append([]string{"RTS_" + strings.ToUpper(strings.ReplaceAll(s.Key, ".", "_"))}, s.LegacyEnvs...)
+}
+
+// SetupViper registers the defaults and environment variables of all settings. configFile is read instead of
+// data/config.toml if set.
+func SetupViper(v *viper.Viper, configFile string) {
+	for _, setting := range Settings {
+		v.SetDefault(setting.Key, setting.Default)
+		v.BindEnv(append([]string{setting.Key}, setting.Env()...)...)
+	}
+
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+	} else {
+		v.SetConfigName("config")
+		v.SetConfigType("toml")
+		v.AddConfigPath("data")
+	}
+}
+
+// LoadAppConfig reads the config file, if there is one, and decodes all settings
+func LoadAppConfig(v *viper.Viper) (*AppConfig, error) {
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		// An explicitly given config file must exist, the default one is optional
+		if !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("error reading config file: %v", err)
+		}
+		Logger.Debugf("No config file found, using flags, environment variables and defaults")
+	}
+
+	var config AppConfig
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("error decoding config: %v", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks the settings that are used by every command
+func (c *AppConfig) Validate() error {
+	var problems []string
+	switch c.Storage {
+	case "file", "sqlite", "postgres":
+	default:
+		problems = append(problems, fmt.Sprintf("storage %q must be file, sqlite or postgres", c.Storage))
+	}
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("log_level %q must be debug, info, warn or error", c.LogLevel))
+	}
+	switch c.PlaylistRange {
+	case "lasthour", "lastday", "lastweek":
+	default:
+		problems = append(problems, fmt.Sprintf("playlist_range %q must be lasthour, lastday or lastweek", c.PlaylistRange))
+	}
+	if c.Cache.Expiration <= 0 {
+		problems = append(problems, "cache.expiration must be positive")
+	}
+	if c.Cache.NoMatchExpiration <= 0 {
+		problems = append(problems, "cache.no_match_expiration must be positive")
+	}
+	if c.Cache.MaxSize <= 0 {
+		problems = append(problems, "cache.max_size must be positive")
+	}
+	if c.Spotify.MinScore < 0 || c.Spotify.MinScore > 1 {
+		problems = append(problems, "spotify.min_score must be between 0 and 1")
+	}
+	for _, problem := range c.Normalize.Problems() {
+		problems = append(problems, "normalize: "+problem)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// SettingSource describes where the value of a setting comes from: "env NAME", "file PATH" or "default". Flags
+// are not known to viper, so they are checked by the caller.
+func SettingSource(v *viper.Viper, setting Setting) string {
+	for _, env := range setting.Env() {
+		if os.Getenv(env) != "" { // Empty variables are ignored by viper as well
+			return "env " + env
+		}
+	}
+	if v.InConfig(setting.Key) {
+		return "file " + v.ConfigFileUsed()
+	}
+	return "default"
+}
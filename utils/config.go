@@ -1,32 +1,76 @@
 package utils
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
 	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 type Station struct {
-	ID         string        `json:"id"`
-	Name       string        `json:"name"`
-	URL        string        `json:"url"`
-	Type       string        `json:"type"`
-	ArtistTag  string        `json:"artistTag,omitempty"`
-	TitleTag   string        `json:"titleTag,omitempty"`
-	ArtistKey  []interface{} `json:"artistKey,omitempty"`
-	TitleKey   []interface{} `json:"titleKey,omitempty"`
-	Regex      string        `json:"regex,omitempty"`
-	PlaylistID string        `json:"playlistId,omitempty"`
+	ID               string                   `json:"id"`
+	Name             string                   `json:"name"`
+	URL              string                   `json:"url"`
+	Type             string                   `json:"type"`
+	ArtistTag        string                   `json:"artistTag,omitempty"`
+	TitleTag         string                   `json:"titleTag,omitempty"`
+	PlayedAtTag      string                   `json:"playedAtTag,omitempty"`
+	DurationTag      string                   `json:"durationTag,omitempty"`
+	AlbumTag         string                   `json:"albumTag,omitempty"`
+	ISRCTag          string                   `json:"isrcTag,omitempty"`
+	ExtraTags        map[string]string        `json:"extraTags,omitempty"`
+	ArtistKey        []interface{}            `json:"artistKey,omitempty"`
+	TitleKey         []interface{}            `json:"titleKey,omitempty"`
+	PlayedAtKey      []interface{}            `json:"playedAtKey,omitempty"`
+	DurationKey      []interface{}            `json:"durationKey,omitempty"`
+	AlbumKey         []interface{}            `json:"albumKey,omitempty"`
+	ISRCKey          []interface{}            `json:"isrcKey,omitempty"`
+	ExtraKeys        map[string][]interface{} `json:"extraKeys,omitempty"`
+	ArtistXPath      string                   `json:"artistXPath,omitempty"`
+	TitleXPath       string                   `json:"titleXPath,omitempty"`
+	PlayedAtXPath    string                   `json:"playedAtXPath,omitempty"`
+	DurationXPath    string                   `json:"durationXPath,omitempty"`
+	AlbumXPath       string                   `json:"albumXPath,omitempty"`
+	ISRCXPath        string                   `json:"isrcXPath,omitempty"`
+	ExtraXPaths      map[string]string        `json:"extraXPaths,omitempty"`
+	Regex            string                   `json:"regex,omitempty"`
+	Separator        string                   `json:"separator,omitempty"`
+	FetchInterval    Duration                 `json:"fetchInterval,omitempty"`
+	Timeout          Duration                 `json:"timeout,omitempty"`
+	UserAgent        string                   `json:"userAgent,omitempty"`
+	Headers          map[string]string        `json:"headers,omitempty"`
+	Retries          *int                     `json:"retries,omitempty"`
+	RetryBackoff     Duration                 `json:"retryBackoff,omitempty"`
+	BreakerThreshold int                      `json:"breakerThreshold,omitempty"`
+	BreakerCooldown  Duration                 `json:"breakerCooldown,omitempty"`
+	PlaylistID       string                   `json:"playlistID,omitempty"`
+	Normalize        *NormalizeRules          `json:"normalize,omitempty"` // Normalization rules applied after the global ones
+	Disabled         bool                     `json:"disabled,omitempty"`  // Disabled stations are kept in the config but not fetched
+
+	source string // File the station was read from
 }
 
 type Config struct {
 	Stations []Station `json:"stations"`
+
+	files map[string]bool // Files the stations were read from, true for files containing a single station
 }
 
+// StationValidator checks a station definition when the config is loaded. It is set by the scraper package, so
+// stations with unknown types or invalid definitions are rejected before they are fetched.
+var StationValidator func(station *Station) error
+
+// Time to wait for further changes of the stations file before reporting a change
+const configWatchDelay = 500 * time.Millisecond
+
 type ConfigHandler struct {
 	mu       sync.Mutex
-	filePath string
+	filePath string // Stations file, or a directory of stations files
 	config   *Config
 }
 
@@ -42,35 +86,165 @@ func NewConfigHandler(filePath string) (*ConfigHandler, error) {
 }
 
 func (h *ConfigHandler) load() error {
-	file, err := os.Open(h.filePath)
+	config, err := h.read()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	var config Config
-	err = json.NewDecoder(file).Decode(&config)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.config = config
+	return nil
+}
+
+// read decodes and validates the stations file, or all stations files of the directory
+func (h *ConfigHandler) read() (*Config, error) {
+	paths, err := stationFilePaths(h.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{files: make(map[string]bool)}
+	for _, path := range paths {
+		stations, single, err := readStationFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for i := range stations {
+			stations[i].source = path
+		}
+		config.Stations = append(config.Stations, stations...)
+		config.files[path] = single
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// isDir reports whether the stations are read from a directory
+func (h *ConfigHandler) isDir() bool {
+	info, err := os.Stat(h.filePath)
+	return err == nil && info.IsDir()
+}
+
+// isStationFile reports whether a changed file is part of the config
+func (h *ConfigHandler) isStationFile(path string) bool {
+	if h.isDir() {
+		return filepath.Dir(filepath.Clean(path)) == filepath.Clean(h.filePath) && isStationFileName(filepath.Base(path))
+	}
+	return filepath.Clean(path) == filepath.Clean(h.filePath)
+}
+
+// Reload reads the stations file again. If it can't be read or is invalid, the current config is kept.
+func (h *ConfigHandler) Reload() error {
+	return h.load()
+}
+
+// Watch calls onChange whenever a stations file changes, until stop is closed. Changes in quick succession are
+// reported once. The directory is watched, as editors and atomic saves replace the file instead of writing to it.
+func (h *ConfigHandler) Watch(onChange func(), stop <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
 	}
+	watchDir := filepath.Dir(h.filePath)
+	if h.isDir() {
+		watchDir = h.filePath
+	}
+	if err := watcher.Add(watchDir); err != nil {
+		watcher.Close()
+		return err
+	}
 
-	h.config = &config
+	go func() {
+		defer watcher.Close()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if h.isStationFile(event.Name) && !event.Has(fsnotify.Chmod) {
+					debounce = time.After(configWatchDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				Logger.Warnf("Error watching %s: %v", h.filePath, err)
+			case <-debounce:
+				debounce = nil
+				onChange()
+			case <-stop:
+				return
+			}
+		}
+	}()
 	return nil
 }
 
-func (h *ConfigHandler) save() error {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+// save writes the files whose stations changed, each in its own format. Single station files of a directory are
+// removed once their station is removed. Must be called with mu held.
+func (h *ConfigHandler) save(config *Config) error {
+	oldFiles := stationsBySource(h.config.Stations)
+	newFiles := stationsBySource(config.Stations)
+	isDir := h.isDir()
 
-	file, err := os.Create(h.filePath)
-	if err != nil {
+	for path, stations := range newFiles {
+		if reflect.DeepEqual(stations, oldFiles[path]) {
+			continue
+		}
+		single, known := config.files[path]
+		if !known {
+			single = isDir // New stations get their own file in a directory
+		}
+		if err := writeStationFile(path, stations, single); err != nil {
+			return err
+		}
+	}
+
+	for path := range oldFiles {
+		if _, ok := newFiles[path]; ok {
+			continue
+		}
+		if isDir && config.files[path] {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		} else if err := writeStationFile(path, []Station{}, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func stationsBySource(stations []Station) map[string][]Station {
+	files := make(map[string][]Station)
+	for _, station := range stations {
+		files[station.source] = append(files[station.source], station)
+	}
+	return files
+}
+
+// replaceStations validates and saves a changed station list, keeping the current config if either fails.
+// Must be called with mu held.
+func (h *ConfigHandler) replaceStations(stations []Station) error {
+	config := *h.config
+	config.Stations = stations
+	if err := config.Validate(); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(h.config)
+	if err := h.save(&config); err != nil {
+		return err
+	}
+	h.config = &config
+	return nil
 }
 
 func (h *ConfigHandler) GetStationByID(id string) (*Station, error) {
@@ -92,14 +266,65 @@ func (h *ConfigHandler) GetAllStations() []Station {
 	return h.config.Stations
 }
 
+// GetEnabledStations returns all stations that are not disabled
+func (h *ConfigHandler) GetEnabledStations() []Station {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var stations []Station
+	for _, station := range h.config.Stations {
+		if !station.Disabled {
+			stations = append(stations, station)
+		}
+	}
+	return stations
+}
+
 func (h *ConfigHandler) UpdateStation(station *Station) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	for i, s := range h.config.Stations {
 		if s.ID == station.ID {
-			h.config.Stations[i] = *station
-			return h.save()
+			stations := append([]Station{}, h.config.Stations...)
+			stations[i] = *station
+			stations[i].source = s.source
+			return h.replaceStations(stations)
+		}
+	}
+	return errors.New("station not found")
+}
+
+// AddStation appends a new station to the config
+func (h *ConfigHandler) AddStation(station *Station) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, s := range h.config.Stations {
+		if s.ID == station.ID {
+			return fmt.Errorf("station %s already exists", station.ID)
+		}
+	}
+
+	added := *station
+	added.source = h.filePath
+	if h.isDir() {
+		added.source = filepath.Join(h.filePath, station.ID+".json")
+	}
+
+	stations := append(append([]Station{}, h.config.Stations...), added)
+	return h.replaceStations(stations)
+}
+
+// RemoveStation removes a station from the config
+func (h *ConfigHandler) RemoveStation(id string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, s := range h.config.Stations {
+		if s.ID == id {
+			stations := append(append([]Station{}, h.config.Stations[:i]...), h.config.Stations[i+1:]...)
+			return h.replaceStations(stations)
 		}
 	}
 	return errors.New("station not found")
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func stationIDs(stations []Station) []string {
+	var ids []string
+	for _, station := range stations {
+		ids = append(ids, station.ID)
+	}
+	return ids
+}
+
+// writeStationDir writes a directory of stations files in every format: a list in JSON, a single station in YAML and
+// TOML, and files that aren't read
+func writeStationDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := writeStationFile(filepath.Join(dir, "a.json"), []Station{testStation("a1"), testStation("a2")}, false); err != nil {
+		t.Fatalf("writeStationFile: %v", err)
+	}
+	if err := writeStationFile(filepath.Join(dir, "b.yaml"), []Station{testStation("b")}, true); err != nil {
+		t.Fatalf("writeStationFile: %v", err)
+	}
+	if err := writeStationFile(filepath.Join(dir, "c.toml"), []Station{testStation("c")}, true); err != nil {
+		t.Fatalf("writeStationFile: %v", err)
+	}
+	writeTestFile(t, filepath.Join(dir, ".d.json.123.tmp"), `{"id": "hidden"}`)
+	writeTestFile(t, filepath.Join(dir, "notes.txt"), "not a stations file")
+	return dir
+}
+
+func TestLoadStationDirectory(t *testing.T) {
+	dir := writeStationDir(t)
+	handler, err := NewConfigHandler(dir)
+	if err != nil {
+		t.Fatalf("NewConfigHandler: %v", err)
+	}
+
+	// The files are merged in name order
+	if got, want := stationIDs(handler.GetAllStations()), []string{"a1", "a2", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stations = %v, want %v", got, want)
+	}
+	station, err := handler.GetStationByID("c")
+	if err != nil || station.source != filepath.Join(dir, "c.toml") || !reflect.DeepEqual(station.ArtistKey, testStation("c").ArtistKey) {
+		t.Errorf("GetStationByID(c) = %+v, %v", station, err)
+	}
+}
+
+func TestLoadDuplicateIDs(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeStationFile(filepath.Join(dir, "a.json"), []Station{testStation("same")}, false); err != nil {
+		t.Fatalf("writeStationFile: %v", err)
+	}
+	if err := writeStationFile(filepath.Join(dir, "b.yaml"), []Station{testStation("same")}, true); err != nil {
+		t.Fatalf("writeStationFile: %v", err)
+	}
+
+	// The error names both files
+	_, err := NewConfigHandler(dir)
+	if err == nil || !strings.Contains(err.Error(), `duplicate id "same"`) || !strings.Contains(err.Error(), "a.json") || !strings.Contains(err.Error(), "b.yaml") {
+		t.Fatalf("NewConfigHandler error = %v, want a duplicate id error naming both files", err)
+	}
+}
+
+func TestSaveStationDirectory(t *testing.T) {
+	dir := writeStationDir(t)
+	handler, err := NewConfigHandler(dir)
+	if err != nil {
+		t.Fatalf("NewConfigHandler: %v", err)
+	}
+	unchanged, err := os.ReadFile(filepath.Join(dir, "a.json"))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	// Added stations get their own JSON file
+	if err := handler.AddStation(&Station{ID: "new", URL: "https://example.com/new", Type: "json", ArtistKey: []interface{}{"artist"}, TitleKey: []interface{}{"title"}}); err != nil {
+		t.Fatalf("AddStation: %v", err)
+	}
+	if err := handler.AddStation(&Station{ID: "b", URL: "https://example.com/b", Type: "json"}); err == nil {
+		t.Error("AddStation accepted an existing id")
+	}
+	stations, single, err := readStationFile(filepath.Join(dir, "new.json"))
+	if err != nil || !single || len(stations) != 1 || stations[0].ID != "new" {
+		t.Errorf("new.json = %v, single %v, %v, want the single station new", stationIDs(stations), single, err)
+	}
+
+	// Edited stations are written back in the format of their file
+	edited := testStation("b")
+	edited.Name = "Renamed"
+	if err := handler.UpdateStation(&edited); err != nil {
+		t.Fatalf("UpdateStation: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "b.yaml"))
+	if err != nil || !isYAML(data) || !strings.Contains(string(data), "Renamed") {
+		t.Errorf("b.yaml after the edit = %s, %v, want the renamed station as YAML", data, err)
+	}
+
+	// Removing the station of a single station file removes the file, removing a station of a list rewrites it
+	if err := handler.RemoveStation("c"); err != nil {
+		t.Fatalf("RemoveStation: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "c.toml")); !os.IsNotExist(err) {
+		t.Errorf("c.toml still exists after removing its station: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "a.json")); err != nil || string(data) != string(unchanged) {
+		t.Errorf("a.json was rewritten without a change to its stations: %v", err)
+	}
+	if err := handler.RemoveStation("a2"); err != nil {
+		t.Fatalf("RemoveStation: %v", err)
+	}
+	if err := handler.RemoveStation("a2"); err == nil {
+		t.Error("RemoveStation of a removed station succeeded")
+	}
+
+	// Loading the directory again gives the saved stations
+	reloaded, err := NewConfigHandler(dir)
+	if err != nil {
+		t.Fatalf("NewConfigHandler after saving: %v", err)
+	}
+	if got, want := stationIDs(reloaded.GetAllStations()), []string{"a1", "b", "new"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stations after saving = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(reloaded.GetAllStations(), handler.GetAllStations()) {
+		t.Errorf("saved stations %+v, want %+v", reloaded.GetAllStations(), handler.GetAllStations())
+	}
+}
+
+func TestSaveSingleStationFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "station.toml")
+	if err := writeStationFile(path, []Station{testStation("a")}, true); err != nil {
+		t.Fatalf("writeStationFile: %v", err)
+	}
+	handler, err := NewConfigHandler(path)
+	if err != nil {
+		t.Fatalf("NewConfigHandler: %v", err)
+	}
+
+	// An edit keeps the single station layout
+	edited := testStation("a")
+	edited.Disabled = true
+	if err := handler.UpdateStation(&edited); err != nil {
+		t.Fatalf("UpdateStation: %v", err)
+	}
+	stations, single, err := readStationFile(path)
+	if err != nil || !single || len(stations) != 1 || !stations[0].Disabled {
+		t.Fatalf("after the edit read %+v, single %v, %v, want the disabled single station", stations, single, err)
+	}
+
+	// A second station turns the file into a list, still in TOML
+	if err := handler.AddStation(&Station{ID: "b", URL: "https://example.com/b", Type: "json"}); err != nil {
+		t.Fatalf("AddStation: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !isTOML(data) {
+		t.Errorf("station.toml after adding = %s, %v, want TOML", data, err)
+	}
+	stations, single, err = readStationFile(path)
+	if err != nil || single || !reflect.DeepEqual(stationIDs(stations), []string{"a", "b"}) {
+		t.Errorf("after adding read %v, single %v, %v, want the list a, b", stationIDs(stations), single, err)
+	}
+
+	// The stations file itself is kept when its last station is removed
+	for _, id := range []string{"a", "b"} {
+		if err := handler.RemoveStation(id); err != nil {
+			t.Fatalf("RemoveStation(%s): %v", id, err)
+		}
+	}
+	stations, _, err = readStationFile(path)
+	if err != nil || len(stations) != 0 {
+		t.Errorf("after removing all stations read %v, %v, want an empty list", stationIDs(stations), err)
+	}
+}
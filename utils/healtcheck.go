@@ -4,21 +4,46 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 )
 
 type HealthStatus struct {
-	Status             string `json:"status"`
-	Message            string `json:"message,omitempty"`
-	LastFetchTime      string `json:"last_fetch_time,omitempty"`
-	LastPlaylistUpdate string `json:"last_playlist_update,omitempty"`
+	Status             string                   `json:"status"`
+	Message            string                   `json:"message,omitempty"`
+	LastFetchTime      string                   `json:"last_fetch_time,omitempty"`
+	LastPlaylistUpdate string                   `json:"last_playlist_update,omitempty"`
+	Breakers           map[string]BreakerStatus `json:"breakers,omitempty"`
+	Cache              *CacheStats              `json:"cache,omitempty"`
+}
+
+// BreakerStatus is the circuit breaker state of a station
+type BreakerStatus struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Since               string `json:"since"`
+}
+
+// CacheStats counts the entries and lookups of the cache of Spotify track IDs. Songs Spotify has no track for are
+// counted separately.
+type CacheStats struct {
+	Matches     int   `json:"matches"`
+	NoMatches   int   `json:"no_matches"`
+	Hits        int64 `json:"hits"`
+	NoMatchHits int64 `json:"no_match_hits"`
+	Misses      int64 `json:"misses"`
 }
 
 var (
+	updateMu               sync.Mutex // Guards the update times, they are set by the workers
 	lastFetchTime          time.Time
-	fetchInterval          time.Duration
+	fetchInterval          func() time.Duration // Computed on each request, the stations may change while running
 	lastPlaylistUpdate     time.Time
 	playlistUpdateInterval time.Duration
+	breakerMu              sync.Mutex
+	breakerStatuses        = make(map[string]BreakerStatus)
+	cacheMu                sync.Mutex
+	cacheStats             *CacheStats
 )
 
 // HealthChecker is an interface for checking the health of services like Spotify and storage
@@ -32,6 +57,9 @@ type HealthChecker interface {
 
 // SetLastUpdateTime updates the timestamp for a given type of update (fetch or playlist)
 func SetLastUpdateTime(updateType string, t time.Time) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
 	switch updateType {
 	case "fetch":
 		lastFetchTime = t
@@ -40,6 +68,30 @@ func SetLastUpdateTime(updateType string, t time.Time) {
 	}
 }
 
+// SetBreakerStatus records the circuit breaker state of a station for the health check
+func SetBreakerStatus(stationID string, state string, failures int) {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+
+	status := BreakerStatus{
+		State:               state,
+		ConsecutiveFailures: failures,
+		Since:               time.Now().Format(time.RFC3339),
+	}
+	if previous, exists := breakerStatuses[stationID]; exists && previous.State == state {
+		status.Since = previous.Since
+	}
+	breakerStatuses[stationID] = status
+}
+
+// SetCacheStats records the cache statistics for the health check
+func SetCacheStats(stats CacheStats) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	cacheStats = &stats
+}
+
 // HealthCheckHandler handles the health check requests
 func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	Logger.Debug("Health check request received")
@@ -48,8 +100,12 @@ func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 		Message: "Service is running",
 	}
 
+	updateMu.Lock()
+	lastFetchTime, lastPlaylistUpdate := lastFetchTime, lastPlaylistUpdate
+	updateMu.Unlock()
+
 	// Check Fetch Ticker
-	if ok, message := checkInterval("fetchTicker", lastFetchTime, fetchInterval); !ok {
+	if ok, message := checkInterval("fetchTicker", lastFetchTime, fetchInterval()); !ok {
 		Logger.Warnf("Fetch ticker is delayed by %s", message)
 		status.Status = "unhealthy"
 		status.Message = message
@@ -84,6 +140,18 @@ func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	// 	}
 	// }
 
+	// Open breakers are reported but don't make the service unhealthy, a single broken station is not worth a restart
+	breakerMu.Lock()
+	status.Breakers = make(map[string]BreakerStatus, len(breakerStatuses))
+	for stationID, breakerStatus := range breakerStatuses {
+		status.Breakers[stationID] = breakerStatus
+	}
+	breakerMu.Unlock()
+
+	cacheMu.Lock()
+	status.Cache = cacheStats
+	cacheMu.Unlock()
+
 	status.LastFetchTime = lastFetchTime.Format(time.RFC3339)
 	status.LastPlaylistUpdate = lastPlaylistUpdate.Format(time.RFC3339)
 
@@ -92,8 +160,8 @@ func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(status)
 }
 
-// StartHealthCheckServer starts an HTTP server to serve health checks
-func StartHealthCheckServer(port int, fetchIntv, playlistIntv time.Duration, spotify HealthChecker) {
+// StartHealthCheckServer starts an HTTP server to serve health checks. fetchIntv returns the current fetch interval.
+func StartHealthCheckServer(port int, fetchIntv func() time.Duration, playlistIntv time.Duration, spotify HealthChecker) {
 	// Initialize the global variables
 	fetchInterval = fetchIntv
 	playlistUpdateInterval = playlistIntv
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// NormalizeRules configures how scraped artists and titles are cleaned up before they are stored. The global rules are
+// set in the [normalize] section of the config file, stations can add their own in "normalize". Settings of a station
+// override the global ones, its lists are applied after the global lists.
+type NormalizeRules struct {
+	DecodeEntities     *bool         `json:"decodeEntities,omitempty" mapstructure:"decode_entities"`         // Decode HTML entities like &amp;
+	CollapseWhitespace *bool         `json:"collapseWhitespace,omitempty" mapstructure:"collapse_whitespace"` // Trim and collapse runs of whitespace
+	FixCase            *bool         `json:"fixCase,omitempty" mapstructure:"fix_case"`                       // Title case names written in all caps
+	ExtractFeatured    *bool         `json:"extractFeatured,omitempty" mapstructure:"extract_featured"`       // Move "feat. X" from the title to the artist
+	ArtistSeparators   []string      `json:"artistSeparators,omitempty" mapstructure:"artist_separators"`     // Separators between artists replaced by " & ", e.g. " / "
+	StripSuffixes      []string      `json:"stripSuffixes,omitempty" mapstructure:"strip_suffixes"`           // Title suffixes removed in brackets or after " - ", e.g. "Radio Edit"
+	Replace            []ReplaceRule `json:"replace,omitempty" mapstructure:"replace"`
+}
+
+// ReplaceRule replaces the matches of a regex in the artist, the title or both
+type ReplaceRule struct {
+	Field       string `json:"field,omitempty" mapstructure:"field"` // artist or title, both if empty
+	Pattern     string `json:"pattern" mapstructure:"pattern"`
+	Replacement string `json:"replacement" mapstructure:"replacement"` // May refer to groups of the pattern as $1 or ${name}
+}
+
+// Problems returns every problem of the rules
+func (r *NormalizeRules) Problems() []string {
+	if r == nil {
+		return nil
+	}
+
+	var problems []string
+	for i, rule := range r.Replace {
+		switch rule.Field {
+		case "", "artist", "title":
+		default:
+			problems = append(problems, fmt.Sprintf("replace rule %d: field %q must be artist or title", i+1, rule.Field))
+		}
+		if rule.Pattern == "" {
+			problems = append(problems, fmt.Sprintf("replace rule %d: missing pattern", i+1))
+		} else if _, err := regexp.Compile(rule.Pattern); err != nil {
+			problems = append(problems, fmt.Sprintf("replace rule %d: invalid pattern %q: %v", i+1, rule.Pattern, err))
+		}
+	}
+	for _, separator := range r.ArtistSeparators {
+		if separator == "" {
+			problems = append(problems, "artist separators must not be empty")
+		}
+	}
+	for _, suffix := range r.StripSuffixes {
+		if suffix == "" {
+			problems = append(problems, "strip suffixes must not be empty")
+		}
+	}
+	return problems
+}
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions of the stations files read from a directory. A single stations file without one of them is read as JSON.
+var stationFileExtensions = map[string]bool{".json": true, ".yaml": true, ".yml": true, ".toml": true}
+
+// stationFilePaths returns the path itself if it is a file, or the stations files of a directory in name order.
+// Hidden files are skipped, which includes the temporary files of atomic saves.
+func stationFilePaths(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && isStationFileName(entry.Name()) {
+			paths = append(paths, filepath.Join(path, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func isStationFileName(name string) bool {
+	return !strings.HasPrefix(name, ".") && stationFileExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// readStationFile reads the stations of a file. A file contains either a config with a list of stations, or a single
+// station. YAML and TOML files are converted to JSON first, so all formats share the JSON field names and decoding.
+func readStationFile(path string) (stations []Station, single bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err = toJSON(path, data)
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, false, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	if _, ok := probe["stations"]; ok {
+		var config Config
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, false, fmt.Errorf("error reading %s: %v", path, err)
+		}
+		return config.Stations, false, nil
+	}
+
+	var station Station
+	if err := json.Unmarshal(data, &station); err != nil {
+		return nil, false, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return []Station{station}, true, nil
+}
+
+// toJSON converts the contents of a YAML or TOML file to JSON
+func toJSON(path string, data []byte) ([]byte, error) {
+	var value map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+	default:
+		return data, nil
+	}
+	return json.Marshal(value)
+}
+
+// writeStationFile atomically writes stations to a file in the format of its extension. A single station file stays
+// a single station as long as it contains one station.
+func writeStationFile(path string, stations []Station, single bool) error {
+	var value interface{} = Config{Stations: stations}
+	if single && len(stations) == 1 {
+		value = stations[0]
+	}
+
+	data, err := encodeStationFile(path, value)
+	if err != nil {
+		return fmt.Errorf("error encoding %s: %v", path, err)
+	}
+	return WriteFileAtomic(path, data)
+}
+
+// encodeStationFile encodes a config or station in the format of the file extension, using the JSON field names
+func encodeStationFile(path string, value interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".yaml" && ext != ".yml" && ext != ".toml" {
+		return append(data, '\n'), nil
+	}
+
+	// Decode numbers as json.Number, so integers like array indexes in key paths aren't written as floats
+	var plain interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&plain); err != nil {
+		return nil, err
+	}
+	plain = plainNumbers(plain)
+
+	if ext == ".toml" {
+		return toml.Marshal(plain)
+	}
+	return yaml.Marshal(plain)
+}
+
+// plainNumbers replaces json.Number values with int64 or float64
+func plainNumbers(value interface{}) interface{} {
+	switch value := value.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = plainNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = plainNumbers(item)
+		}
+	}
+	return value
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it over path, so the file is never left
+// half written
+func WriteFileAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	defer os.Remove(tmpPath) // Fails harmlessly once the file is renamed
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	// Keep the permissions of an existing file instead of the 0600 of the temporary file
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStation returns a valid station with a key path containing an index, which must survive every format as a number
+func testStation(id string) Station {
+	return Station{
+		ID:            id,
+		Name:          "Station " + id,
+		URL:           "https://example.com/" + id,
+		Type:          "json",
+		ArtistKey:     []interface{}{"songs", float64(0), "artist"},
+		TitleKey:      []interface{}{"songs", float64(0), "title"},
+		FetchInterval: Duration(30 * time.Second),
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestReadStationFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		content    string
+		wantIDs    []string
+		wantSingle bool
+	}{
+		{
+			name:    "json list",
+			file:    "stations.json",
+			content: `{"stations": [{"id": "a", "url": "https://example.com/a", "type": "json", "artistKey": ["songs", 0, "artist"], "fetchInterval": "30s"}, {"id": "b"}]}`,
+			wantIDs: []string{"a", "b"},
+		},
+		{
+			name:       "json single",
+			file:       "a.json",
+			content:    `{"id": "a", "url": "https://example.com/a", "type": "json", "artistKey": ["songs", 0, "artist"], "fetchInterval": "30s"}`,
+			wantIDs:    []string{"a"},
+			wantSingle: true,
+		},
+		{
+			name:    "yaml list",
+			file:    "stations.yaml",
+			content: "stations:\n  - id: a\n    url: https://example.com/a\n    type: json\n    artistKey: [songs, 0, artist]\n    fetchInterval: 30s\n  - id: b\n",
+			wantIDs: []string{"a", "b"},
+		},
+		{
+			name:       "yml single",
+			file:       "a.yml",
+			content:    "id: a\nurl: https://example.com/a\ntype: json\nartistKey: [songs, 0, artist]\nfetchInterval: 30s\n",
+			wantIDs:    []string{"a"},
+			wantSingle: true,
+		},
+		{
+			name:    "toml list",
+			file:    "stations.toml",
+			content: "[[stations]]\nid = \"a\"\nurl = \"https://example.com/a\"\ntype = \"json\"\nartistKey = [\"songs\", 0, \"artist\"]\nfetchInterval = \"30s\"\n\n[[stations]]\nid = \"b\"\n",
+			wantIDs: []string{"a", "b"},
+		},
+		{
+			name:       "toml single",
+			file:       "a.toml",
+			content:    "id = \"a\"\nurl = \"https://example.com/a\"\ntype = \"json\"\nartistKey = [\"songs\", 0, \"artist\"]\nfetchInterval = \"30s\"\n",
+			wantIDs:    []string{"a"},
+			wantSingle: true,
+		},
+		{
+			name:       "no extension is json",
+			file:       "stations",
+			content:    `{"id": "a", "url": "https://example.com/a", "type": "json", "artistKey": ["songs", 0, "artist"], "fetchInterval": 30}`,
+			wantIDs:    []string{"a"},
+			wantSingle: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			writeTestFile(t, path, tt.content)
+
+			stations, single, err := readStationFile(path)
+			if err != nil {
+				t.Fatalf("readStationFile: %v", err)
+			}
+			var ids []string
+			for _, station := range stations {
+				ids = append(ids, station.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) || single != tt.wantSingle {
+				t.Fatalf("readStationFile = %v, single %v, want %v, single %v", ids, single, tt.wantIDs, tt.wantSingle)
+			}
+
+			// All formats decode to the same station
+			first := stations[0]
+			want := Station{ID: "a", URL: "https://example.com/a", Type: "json", ArtistKey: []interface{}{"songs", float64(0), "artist"}, FetchInterval: Duration(30 * time.Second)}
+			if !reflect.DeepEqual(first, want) {
+				t.Errorf("station = %+v, want %+v", first, want)
+			}
+		})
+	}
+}
+
+func TestReadStationFileInvalid(t *testing.T) {
+	for file, content := range map[string]string{
+		"broken.json": `{"stations": [`,
+		"broken.yaml": "stations: [a\n",
+		"broken.toml": "[[stations]\n",
+		"list.json":   `[{"id": "a"}]`,
+	} {
+		path := filepath.Join(t.TempDir(), file)
+		writeTestFile(t, path, content)
+		if _, _, err := readStationFile(path); err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("readStationFile(%s) error = %v, want an error naming the file", file, err)
+		}
+	}
+}
+
+func TestWriteStationFile(t *testing.T) {
+	tests := []struct {
+		file       string
+		stations   int
+		single     bool
+		wantSingle bool
+		isFormat   func(data []byte) bool
+	}{
+		{file: "list.json", stations: 2, isFormat: json.Valid},
+		{file: "single.json", stations: 1, single: true, wantSingle: true, isFormat: json.Valid},
+		{file: "list.yaml", stations: 2, isFormat: isYAML},
+		{file: "single.yml", stations: 1, single: true, wantSingle: true, isFormat: isYAML},
+		{file: "list.toml", stations: 2, isFormat: isTOML},
+		{file: "single.toml", stations: 1, single: true, wantSingle: true, isFormat: isTOML},
+		// A single station file with a second station becomes a list
+		{file: "grown.yaml", stations: 2, single: true, isFormat: isYAML},
+		{file: "empty.toml", stations: 0, isFormat: isTOML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			stations := []Station{}
+			for i := 0; i < tt.stations; i++ {
+				stations = append(stations, testStation(string(rune('a'+i))))
+			}
+
+			if err := writeStationFile(path, stations, tt.single); err != nil {
+				t.Fatalf("writeStationFile: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if !tt.isFormat(data) {
+				t.Errorf("%s is not written in the format of its extension:\n%s", tt.file, data)
+			}
+
+			read, single, err := readStationFile(path)
+			if err != nil {
+				t.Fatalf("readStationFile: %v", err)
+			}
+			// An empty list may be read back as nil
+			if len(read) != len(stations) || (len(read) > 0 && !reflect.DeepEqual(read, stations)) {
+				t.Errorf("read back %+v, want %+v", read, stations)
+			}
+			if single != tt.wantSingle {
+				t.Errorf("read back single %v, want %v", single, tt.wantSingle)
+			}
+		})
+	}
+}
+
+func isYAML(data []byte) bool {
+	return !json.Valid(data) && !strings.Contains(string(data), " = ")
+}
+
+func isTOML(data []byte) bool {
+	return !json.Valid(data) && strings.Contains(string(data), " = ")
+}
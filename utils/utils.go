@@ -1,11 +0,0 @@
-package utils
-
-import "os"
-
-// getEnv reads an environment variable or returns a default value
-func GetEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	return defaultValue
-}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Station IDs end up in file names, so only letters, digits and underscores are allowed
+var stationIDRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// ValidationError lists every problem found in a station config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid station config (%d problems):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Validate checks all stations and reports all problems at once. Type specific checks are done by StationValidator.
+func (c *Config) Validate() error {
+	var problems []string
+	seenIDs := make(map[string]int)
+
+	for i := range c.Stations {
+		station := &c.Stations[i]
+		label := stationLabel(i, station)
+		addProblem := func(format string, args ...interface{}) {
+			problems = append(problems, label+": "+fmt.Sprintf(format, args...))
+		}
+
+		switch {
+		case station.ID == "":
+			addProblem("missing id")
+		case !stationIDRegex.MatchString(station.ID):
+			addProblem("id %q may only contain letters, digits and underscores", station.ID)
+		}
+		if first, exists := seenIDs[station.ID]; exists && station.ID != "" {
+			addProblem("duplicate id %q, already used by %s", station.ID, stationLabel(first, &c.Stations[first]))
+		} else {
+			seenIDs[station.ID] = i
+		}
+
+		if station.URL == "" {
+			addProblem("missing url")
+		} else if u, err := url.Parse(station.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addProblem("url %q is not a valid http(s) URL", station.URL)
+		}
+
+		if station.Type == "" {
+			addProblem("missing type")
+		} else if StationValidator != nil {
+			if err := StationValidator(station); err != nil {
+				addProblem("%v", err)
+			}
+		}
+
+		if station.FetchInterval < 0 || station.Timeout < 0 || station.RetryBackoff < 0 || station.BreakerCooldown < 0 {
+			addProblem("durations must not be negative")
+		}
+		if station.Retries != nil && *station.Retries < 0 {
+			addProblem("retries must not be negative")
+		}
+		for _, problem := range station.Normalize.Problems() {
+			addProblem("normalize: %s", problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func stationLabel(index int, station *Station) string {
+	label := stationName(index, station)
+	if station.source != "" {
+		label += " in " + station.source
+	}
+	return label
+}
+
+func stationName(index int, station *Station) string {
+	switch {
+	case station.Name != "" && station.ID != "":
+		return fmt.Sprintf("station %q (%s)", station.Name, station.ID)
+	case station.ID != "":
+		return fmt.Sprintf("station %s", station.ID)
+	case station.Name != "":
+		return fmt.Sprintf("station %q", station.Name)
+	default:
+		return fmt.Sprintf("station #%d", index+1)
+	}
+}